@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kjuulh/scaffold/internal/templates"
+)
+
+// doctorReport is how scaffold doctor prints the health of the registries, it is kept stable for tooling built on top of the json and yaml output
+type doctorReport struct {
+	Registries []doctorRegistry  `json:"registries" yaml:"registries"`
+	Problems   []doctorDiagnosis `json:"problems" yaml:"problems"`
+}
+
+type doctorRegistry struct {
+	Name      string `json:"name" yaml:"name"`
+	Commit    string `json:"commit" yaml:"commit"`
+	Templates int    `json:"templates" yaml:"templates"`
+	// Error is why the registry couldn't be fetched or indexed, empty if it could
+	Error string `json:"error" yaml:"error"`
+}
+
+type doctorDiagnosis struct {
+	Registry string `json:"registry" yaml:"registry"`
+	Template string `json:"template" yaml:"template"`
+	// Severity is error if the template can't be used, and warning if it can
+	Severity string `json:"severity" yaml:"severity"`
+	Error    string `json:"error" yaml:"error"`
+}
+
+func newDoctorCommand(flags *rootFlags) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:          "doctor",
+		Short:        "check that the registries can be fetched, and report the templates which can't be loaded",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains([]string{"table", "json", "yaml"}, output) {
+				return fmt.Errorf("unknown output: %s, expected table, json or yaml", output)
+			}
+
+			ctx := cmd.Context()
+			ui := flags.logger()
+
+			sources, err := flags.fetcher().Sources(flags.registryPath)
+			if err != nil {
+				return fmt.Errorf("failed to find registries: %w", err)
+			}
+
+			// Every registry is checked, even if some of them fail, so all the problems are reported at once
+			templateIndexer := templates.NewTemplateIndexer()
+			registries := make([]doctorRegistry, len(sources))
+			registryTemplates := make([][]templates.Template, len(sources))
+			egrp, ctx := errgroup.WithContext(ctx)
+			for i, source := range sources {
+				egrp.Go(func() error {
+					registries[i].Name = source.Name()
+
+					registry, err := source.Fetch(ctx, ui)
+					if err != nil {
+						registries[i].Error = err.Error()
+						return nil
+					}
+					registries[i].Commit = registry.Commit
+
+					registryTemplates[i], err = templateIndexer.IndexRegistry(ctx, registry, ui)
+					if err != nil {
+						registries[i].Error = err.Error()
+						return nil
+					}
+
+					return nil
+				})
+			}
+
+			if err := egrp.Wait(); err != nil {
+				return err
+			}
+
+			// The templates are resolved together, as they can use templates from the other registries
+			for _, template := range templateIndexer.Resolve(slices.Concat(registryTemplates...)) {
+				for i := range registries {
+					if registries[i].Name == template.Registry {
+						registries[i].Templates++
+					}
+				}
+			}
+
+			report := doctorReport{Registries: registries, Problems: make([]doctorDiagnosis, 0)}
+			for _, diagnostic := range templateIndexer.Diagnostics() {
+				severity := "error"
+				if diagnostic.Warning {
+					severity = "warning"
+				}
+
+				report.Problems = append(report.Problems, doctorDiagnosis{
+					Registry: diagnostic.Registry,
+					Template: diagnostic.Template(),
+					Severity: severity,
+					Error:    diagnostic.Err.Error(),
+				})
+			}
+
+			if err := printDoctorReport(cmd.OutOrStdout(), output, report); err != nil {
+				return err
+			}
+
+			if problems := report.problems(); problems > 0 {
+				return fmt.Errorf("found %d problems", problems)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "how to print the report: table, json or yaml")
+
+	return cmd
+}
+
+// problems counts the registries which failed, and the templates which can't be loaded. Warnings are printed, but aren't counted
+func (r doctorReport) problems() int {
+	problems := 0
+	for _, problem := range r.Problems {
+		if problem.Severity == "error" {
+			problems++
+		}
+	}
+
+	for _, registry := range r.Registries {
+		if registry.Error != "" {
+			problems++
+		}
+	}
+
+	return problems
+}
+
+func printDoctorReport(out io.Writer, output string, report doctorReport) error {
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(report)
+	case "yaml":
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	case "table":
+		writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "REGISTRY\tCOMMIT\tTEMPLATES\tSTATUS")
+
+		for _, registry := range report.Registries {
+			status := "ok"
+			if registry.Error != "" {
+				status = registry.Error
+			}
+
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", registry.Name, registry.Commit, registry.Templates, status)
+		}
+
+		if len(report.Problems) > 0 {
+			fmt.Fprintln(writer, "\nTEMPLATE\tSEVERITY\tPROBLEM")
+			for _, problem := range report.Problems {
+				fmt.Fprintf(writer, "%s\t%s\t%s\n", problem.Template, problem.Severity, problem.Error)
+			}
+		}
+
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown output: %s, expected table, json or yaml", output)
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kjuulh/scaffold/internal/templates"
+)
+
+func newLintCommand(flags *rootFlags) *cobra.Command {
+	var (
+		output string
+		strict bool
+	)
+
+	cmd := &cobra.Command{
+		Use:          "lint [registry or template]",
+		Short:        "validate the templates of a registry, or a single template, defaults to the registry folder of the current directory",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains([]string{"text", "json", "yaml"}, output) {
+				return fmt.Errorf("unknown output: %s, expected text, json or yaml", output)
+			}
+
+			dir := "."
+			if len(args) == 1 {
+				dir = args[0]
+			} else if info, err := os.Stat("registry"); err == nil && info.IsDir() {
+				dir = "registry"
+			}
+
+			issues, err := templates.NewLinter().Lint(cmd.Context(), dir, flags.logger())
+			if err != nil {
+				return err
+			}
+
+			if err := printLintIssues(cmd.OutOrStdout(), output, issues); err != nil {
+				return err
+			}
+
+			errorCount, warningCount := countLintIssues(issues)
+			if errorCount > 0 || (strict && warningCount > 0) {
+				return fmt.Errorf("found %d errors and %d warnings", errorCount, warningCount)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "how to print the issues: text, json or yaml")
+	cmd.Flags().BoolVar(&strict, "strict", false, "fail on warnings as well as errors")
+
+	return cmd
+}
+
+func countLintIssues(issues []templates.LintIssue) (int, int) {
+	var errorCount, warningCount int
+	for _, issue := range issues {
+		switch issue.Severity {
+		case templates.LintSeverityError:
+			errorCount++
+		case templates.LintSeverityWarning:
+			warningCount++
+		}
+	}
+
+	return errorCount, warningCount
+}
+
+func printLintIssues(out io.Writer, output string, issues []templates.LintIssue) error {
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(issues)
+	case "yaml":
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(issues); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	case "text":
+		for _, issue := range issues {
+			fmt.Fprintln(out, issue.String())
+		}
+
+		errorCount, warningCount := countLintIssues(issues)
+		fmt.Fprintf(out, "%d errors, %d warnings\n", errorCount, warningCount)
+
+		return nil
+	default:
+		return fmt.Errorf("unknown output: %s, expected text, json or yaml", output)
+	}
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kjuulh/scaffold/internal/templates"
+)
+
+type listFlags struct {
+	output   string
+	tags     []string
+	registry string
+}
+
+// listedTemplate is how a template is printed by scaffold list, it is kept stable for tooling built on top of the json and yaml output
+type listedTemplate struct {
+	Name        string   `json:"name" yaml:"name"`
+	FullName    string   `json:"fullName" yaml:"fullName"`
+	Registry    string   `json:"registry" yaml:"registry"`
+	Category    string   `json:"category" yaml:"category"`
+	Description string   `json:"description" yaml:"description"`
+	Tags        []string `json:"tags" yaml:"tags"`
+	Owners      []string `json:"owners" yaml:"owners"`
+	Version     string   `json:"version" yaml:"version"`
+	Deprecated  bool     `json:"deprecated" yaml:"deprecated"`
+	Replacement string   `json:"replacement" yaml:"replacement"`
+	// MinScaffoldVersion is the oldest scaffold which can use the template
+	MinScaffoldVersion string        `json:"minScaffoldVersion" yaml:"minScaffoldVersion"`
+	Inputs             []listedInput `json:"inputs" yaml:"inputs"`
+	// Modes are the write modes other than write configured in scaffold.yaml, a file can still choose its mode from its template, which is only known when it is rendered
+	Modes []string `json:"modes" yaml:"modes"`
+}
+
+type listedInput struct {
+	Name        string `json:"name" yaml:"name"`
+	Type        string `json:"type" yaml:"type"`
+	Description string `json:"description" yaml:"description"`
+	Default     string `json:"default" yaml:"default"`
+}
+
+func newListCommand(flags *rootFlags) *cobra.Command {
+	var listFlags listFlags
+
+	cmd := &cobra.Command{
+		Use:          "list",
+		Short:        "list the available templates",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains([]string{"table", "json", "yaml"}, listFlags.output) {
+				return fmt.Errorf("unknown output: %s, expected table, json or yaml", listFlags.output)
+			}
+
+			ctx := cmd.Context()
+			ui := flags.logger()
+
+			sources, err := flags.fetcher().Sources(flags.registryPath)
+			if err != nil {
+				return fmt.Errorf("failed to find registries: %w", err)
+			}
+
+			templateIndexer := templates.NewTemplateIndexer()
+			availableTemplates, err := templateIndexer.IndexSources(ctx, sources, ui)
+			if err != nil {
+				return fmt.Errorf("failed to index templates: %w", err)
+			}
+			availableTemplates = projectTemplates(flags.project, availableTemplates)
+
+			if err := printTemplates(cmd.OutOrStdout(), listFlags, filterTemplates(availableTemplates, listFlags)); err != nil {
+				return err
+			}
+
+			if skipped := templates.SkippedTemplates(templateIndexer.Diagnostics()); skipped > 0 {
+				ui.Warn("some templates could not be loaded, run scaffold doctor for details", "templates", skipped)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&listFlags.output, "output", "o", "table", "how to print the templates: table, json or yaml")
+	cmd.Flags().StringSliceVar(&listFlags.tags, "tag", nil, "only list templates with all of the given tags")
+	cmd.Flags().StringVar(&listFlags.registry, "from", "", "only list templates from the given registry")
+
+	return cmd
+}
+
+// filterTemplates returns the templates matching the tag and registry filters, sorted by their full name
+func filterTemplates(availableTemplates []templates.Template, listFlags listFlags) []listedTemplate {
+	listedTemplates := make([]listedTemplate, 0, len(availableTemplates))
+	for _, template := range availableTemplates {
+		if listFlags.registry != "" && template.Registry != listFlags.registry {
+			continue
+		}
+
+		if !hasTags(template, listFlags.tags) {
+			continue
+		}
+
+		listedTemplates = append(listedTemplates, newListedTemplate(template))
+	}
+
+	sort.Slice(listedTemplates, func(i, j int) bool {
+		return listedTemplates[i].FullName < listedTemplates[j].FullName
+	})
+
+	return listedTemplates
+}
+
+func hasTags(template templates.Template, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(template.File.Tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newListedTemplate(template templates.Template) listedTemplate {
+	inputs := make([]listedInput, 0, len(template.File.Input))
+	for name, input := range template.File.Input {
+		inputs = append(inputs, listedInput{
+			Name:        name,
+			Type:        input.Type,
+			Description: input.Description,
+			Default:     input.Default,
+		})
+	}
+
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].Name < inputs[j].Name
+	})
+
+	tags := template.File.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	owners := template.File.Owners
+	if owners == nil {
+		owners = []string{}
+	}
+
+	modes := make([]string, 0)
+	for _, fileConfig := range template.File.Files {
+		if fileConfig.Mode != "" && fileConfig.Mode != "write" && !slices.Contains(modes, fileConfig.Mode) {
+			modes = append(modes, fileConfig.Mode)
+		}
+	}
+	slices.Sort(modes)
+
+	return listedTemplate{
+		Name:               template.File.Name,
+		FullName:           template.FullName(),
+		Registry:           template.Registry,
+		Category:           template.Category,
+		Description:        template.File.Description,
+		Tags:               tags,
+		Owners:             owners,
+		Version:            template.File.Version,
+		Deprecated:         template.File.Deprecated,
+		Replacement:        template.File.Replacement,
+		MinScaffoldVersion: template.File.MinScaffoldVersion,
+		Inputs:             inputs,
+		Modes:              modes,
+	}
+}
+
+func printTemplates(out io.Writer, listFlags listFlags, listedTemplates []listedTemplate) error {
+	switch listFlags.output {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(listedTemplates)
+	case "yaml":
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(listedTemplates); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	case "table":
+		writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tVERSION\tREGISTRY\tDESCRIPTION\tTAGS\tINPUTS\tMODES")
+
+		for _, template := range listedTemplates {
+			inputs := make([]string, 0, len(template.Inputs))
+			for _, input := range template.Inputs {
+				inputs = append(inputs, input.Name)
+			}
+
+			description := template.Description
+			if template.Deprecated {
+				description = strings.TrimSpace(fmt.Sprintf("(%s) %s", deprecationNote(template.Replacement), description))
+			}
+
+			fmt.Fprintf(
+				writer,
+				"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				template.FullName,
+				template.Version,
+				template.Registry,
+				description,
+				strings.Join(template.Tags, ","),
+				strings.Join(inputs, ","),
+				strings.Join(template.Modes, ","),
+			)
+		}
+
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown output: %s, expected table, json or yaml", listFlags.output)
+	}
+}
+
+// deprecationNote is how a deprecated template is marked, pointing to its replacement if it has one
+func deprecationNote(replacement string) string {
+	if replacement == "" {
+		return "deprecated"
+	}
+
+	return fmt.Sprintf("deprecated, use %s", replacement)
+}
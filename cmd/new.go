@@ -4,40 +4,105 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"os"
 
-	"github.com/golang-cz/devslog"
-	"github.com/kjuulh/scaffold/internal/fetcher"
 	"github.com/kjuulh/scaffold/internal/templates"
 	"github.com/spf13/cobra"
 )
 
-func getScaffoldCommands(registryPath *string, forceCacheUpdate *bool) ([]*cobra.Command, error) {
+// templateRunner is run by a template command, with the inputs of the flags set on the template, and the path the files are scaffolded to
+type templateRunner func(cmd *cobra.Command, template *templates.Template, templatePath string) error
+
+// availableTemplates returns the templates of the registries which are already on disk, as the template commands are created before cobra runs
+func availableTemplates(flags *rootFlags) ([]templates.Template, error) {
 	var (
 		ctx             = context.Background()
-		ui              = slog.New(devslog.NewHandler(os.Stderr, &devslog.Options{HandlerOptions: &slog.HandlerOptions{Level: slog.LevelInfo}}))
-		fetcher         = fetcher.NewFetcher(*forceCacheUpdate)
+		ui              = flags.logger()
+		fetcher         = flags.fetcher()
 		templateIndexer = templates.NewTemplateIndexer()
-		templateLoader  = templates.NewTemplateLoader(ui)
-		fileWriter      = templates.NewFileWriter().WithPromptOverride(promptOverrideFile)
 	)
 
-	// if err := fetcher.CloneRepository(ctx, registryPath, ui); err != nil {
-	// 	return nil, fmt.Errorf("failed to clone repository: %w", err)
-	// }
-
-	localRegistryPath, available := fetcher.Available(registryPath)
-	if !available {
+	localRegistries := fetcher.Available(flags.registryPath)
+	if len(localRegistries) == 0 {
 		return nil, nil
 	}
 
-	templateFiles, err := templateIndexer.Index(ctx, localRegistryPath, ui)
+	templateFiles, err := indexRegistries(ctx, templateIndexer, localRegistries, ui)
 	if err != nil {
 		return nil, fmt.Errorf("failed to index templates: %w", err)
 	}
 
+	return projectTemplates(flags.project, templateFiles), nil
+}
+
+func getScaffoldCommands(flags *rootFlags, availableTemplates []templates.Template, reserved map[string]bool) []*cobra.Command {
+	var (
+		ui             = flags.logger()
+		templateLoader = templates.NewTemplateLoader(ui)
+		fileWriter     = templates.NewFileWriter().WithPromptOverride(promptOverrideFile)
+	)
+
+	return templateCommands(ui, availableTemplates, reserved, func(cmd *cobra.Command, template *templates.Template, templatePath string) error {
+		ctx := cmd.Context()
+
+		if err := template.CheckUsable(ui, flags.version); err != nil {
+			return err
+		}
+
+		files, err := templateLoader.Load(ctx, template)
+		if err != nil {
+			return fmt.Errorf("failed to load template files: %w", err)
+		}
+
+		templatedFiles, err := templateLoader.TemplateFiles(template, files, templatePath)
+		if err != nil {
+			return fmt.Errorf("failed to template files: %w", err)
+		}
+
+		usedTemplates, err := templateLoader.ResolveDependencies(template, templatePath)
+		if err != nil {
+			return fmt.Errorf("failed to template the used templates: %w", err)
+		}
+
+		dependencyFiles, err := templateLoader.TemplateUsed(ctx, template, usedTemplates)
+		if err != nil {
+			return fmt.Errorf("failed to template the used templates: %w", err)
+		}
+		templatedFiles = append(templatedFiles, dependencyFiles...)
+
+		ui.Info("Templated files", "files", len(templatedFiles))
+
+		hookRunner := flags.hookRunner()
+		hooks, err := hookRunner.PlanAll(ui, template, templatePath, usedTemplates)
+		if err != nil {
+			return fmt.Errorf("failed to prepare hooks: %w", err)
+		}
+
+		if err := hookRunner.RunAll(ctx, ui, templates.HookStagePre, hooks); err != nil {
+			return fmt.Errorf("failed to run pre hooks: %w", err)
+		}
+
+		if err := fileWriter.Write(ctx, ui, templatedFiles); err != nil {
+			return fmt.Errorf("failed to write files: %w", err)
+		}
+
+		if err := hookRunner.RunAll(ctx, ui, templates.HookStagePost, hooks); err != nil {
+			return fmt.Errorf("failed to run post hooks: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// templateCommands creates a command per template, with a flag per input of the template. Templates named like one of the reserved commands are skipped
+func templateCommands(ui *slog.Logger, availableTemplates []templates.Template, reserved map[string]bool, run templateRunner) []*cobra.Command {
+	// Templates are addressable by their plain name as well, as long as it is unique across registries
+	templateNames := make(map[string]int)
+	for _, template := range availableTemplates {
+		templateNames[template.File.Name]++
+	}
+
 	commands := make([]*cobra.Command, 0)
-	for _, template := range templateFiles {
+	for _, template := range availableTemplates {
 		var templatePath string
 		variables := make([]*LazyVariable, 0)
 
@@ -49,8 +114,20 @@ func getScaffoldCommands(registryPath *string, forceCacheUpdate *bool) ([]*cobra
 			})
 		}
 
+		if reserved[template.FullName()] {
+			ui.Warn("template has the name of a built in command, use it through the picker instead", "template", template.FullName())
+			continue
+		}
+
+		var aliases []string
+		if template.FullName() != template.File.Name && templateNames[template.File.Name] == 1 && !reserved[template.File.Name] {
+			aliases = append(aliases, template.File.Name)
+		}
+
 		cmd := &cobra.Command{
-			Use:          template.File.Name,
+			Use:          template.FullName(),
+			Aliases:      aliases,
+			Short:        template.File.Description,
 			SilenceUsage: true,
 			RunE: func(cmd *cobra.Command, args []string) error {
 				ui.Info("Loading template files", "name", template.File.Name)
@@ -69,23 +146,7 @@ func getScaffoldCommands(registryPath *string, forceCacheUpdate *bool) ([]*cobra
 					templatePath = scaffoldDest
 				}
 
-				files, err := templateLoader.Load(ctx, &template)
-				if err != nil {
-					return fmt.Errorf("failed to load template files: %w", err)
-				}
-
-				templatedFiles, err := templateLoader.TemplateFiles(&template, files, templatePath)
-				if err != nil {
-					return fmt.Errorf("failed to template files: %w", err)
-				}
-
-				ui.Info("Templated files", "files", len(templatedFiles))
-
-				if err := fileWriter.Write(ctx, ui, templatedFiles); err != nil {
-					return fmt.Errorf("failed to write files: %w", err)
-				}
-
-				return nil
+				return run(cmd, &template, templatePath)
 			},
 		}
 
@@ -98,7 +159,7 @@ func getScaffoldCommands(registryPath *string, forceCacheUpdate *bool) ([]*cobra
 		commands = append(commands, cmd)
 	}
 
-	return commands, nil
+	return commands
 }
 
 type LazyVariable struct {
@@ -9,46 +9,165 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/golang-cz/devslog"
 	"github.com/ktr0731/go-fuzzyfinder"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
 
+	"github.com/kjuulh/scaffold/internal/config"
 	"github.com/kjuulh/scaffold/internal/fetcher"
+	"github.com/kjuulh/scaffold/internal/home"
 	"github.com/kjuulh/scaffold/internal/templates"
 )
 
-func Execute() error {
-	var (
-		registryPath     string
-		forceCacheUpdate bool
-	)
+// rootFlags are the persistent flags shared by all commands
+type rootFlags struct {
+	registryPath     string
+	forceCacheUpdate bool
+	noHooks          bool
+	yes              bool
+	offline          bool
+	cacheTTL         time.Duration
+	home             string
+	// version is the version of the scaffold binary, templates can require a minimum version
+	version string
+
+	config  config.Config
+	project config.Project
+}
+
+func (r *rootFlags) dirs() home.Dirs {
+	return home.Resolve(r.home)
+}
+
+// hookRunner asks before running the hooks of a template, unless they're disabled or confirmed up front with --yes
+func (r *rootFlags) hookRunner() *templates.HookRunner {
+	hookRunner := templates.NewHookRunner().WithDisabled(r.noHooks)
+	if !r.yes {
+		hookRunner = hookRunner.WithPromptConfirm(promptConfirmHooks)
+	}
+
+	return hookRunner
+}
+
+func (r *rootFlags) fetcher() *fetcher.Fetcher {
+	return fetcher.
+		NewFetcher(r.forceCacheUpdate).
+		WithTTL(r.cacheTTL).
+		WithOffline(r.offline).
+		WithCacheDir(r.dirs().Cache).
+		WithRegistries(r.registries())
+}
+
+func (r *rootFlags) registries() []fetcher.Registry {
+	registries := make([]fetcher.Registry, 0, len(r.config.Registries))
+	for _, registry := range r.config.Registries {
+		url, ref := fetcher.ParseRegistryURL(registry.URL)
+		registries = append(registries, fetcher.Registry{Name: registry.Name, URL: url, Ref: ref})
+	}
+
+	return registries
+}
+
+func (r *rootFlags) logger() *slog.Logger {
+	// The level is validated when the config is loaded
+	level, _ := r.config.UI.Level()
+
+	return slog.New(devslog.NewHandler(os.Stderr, &devslog.Options{
+		HandlerOptions: &slog.HandlerOptions{
+			Level: level,
+		},
+		NoColor: r.config.UI.NoColor,
+	}))
+}
+
+// loadConfig reads the global and project config, the flags which are set take precedence over them
+func (r *rootFlags) loadConfig(cmd *cobra.Command) error {
+	globalConfig, err := config.Load(r.dirs().Config)
+	if err != nil {
+		return err
+	}
+
+	project, err := config.LoadProject(".")
+	if err != nil {
+		return err
+	}
+
+	r.config = globalConfig
+	r.project = project
+
+	if !cmd.PersistentFlags().Changed("cache-ttl") && globalConfig.CacheTTL != 0 {
+		r.cacheTTL = globalConfig.CacheTTL
+	}
+
+	if !cmd.PersistentFlags().Changed("offline") && globalConfig.Offline {
+		r.offline = true
+	}
+
+	if r.registryPath == "" {
+		r.registryPath = project.RegistryPath()
+	}
+
+	if dirs := r.dirs(); dirs.Legacy != "" {
+		xdg := home.XDG()
+		r.logger().Warn(
+			"~/.scaffold from an earlier version is still used, move its config.yaml to the config folder, and remove it to cache the registries in the cache folder",
+			"legacy", dirs.Legacy,
+			"config", xdg.Config,
+			"cache", xdg.Cache,
+		)
+	}
+
+	return nil
+}
+
+func Execute(version string) error {
+	flags := rootFlags{version: version}
 
 	rootCmd := &cobra.Command{
-		Use:   "scaffold",
-		Short: "pick a template, and scaffold a piece of code",
+		Use:     "scaffold",
+		Short:   "pick a template, and scaffold a piece of code",
+		Version: version,
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := runScaffold(cmd.Context(), &registryPath, &forceCacheUpdate); err != nil {
+			if err := runScaffold(cmd.Context(), &flags); err != nil {
 				fmt.Printf("failed to run scaffold: %s\n", err.Error())
 				os.Exit(1)
 			}
 		},
 	}
 
-	rootCmd.PersistentFlags().StringVar(&registryPath, "registry", "", "where to get the registry from, defaults to upstream repository")
-	rootCmd.PersistentFlags().BoolVar(&forceCacheUpdate, "force-cache-update", false, "should we force an update of the cache?")
-	if err := rootCmd.ParseFlags(os.Args); err != nil {
+	rootCmd.PersistentFlags().StringVar(&flags.registryPath, "registry", "", "where to get the registry from: a local directory, a git url, or a tarball or zip archive. Defaults to the upstream repositories")
+	rootCmd.PersistentFlags().BoolVar(&flags.forceCacheUpdate, "force-cache-update", false, "should we force an update of the cache?")
+	rootCmd.PersistentFlags().BoolVar(&flags.noHooks, "no-hooks", false, "skip the pre and post hooks of the template")
+	rootCmd.PersistentFlags().BoolVarP(&flags.yes, "yes", "y", false, "run the hooks of the template without asking, i.e. in CI")
+	rootCmd.PersistentFlags().BoolVar(&flags.offline, "offline", false, "never touch the network, use whatever registries are cached")
+	rootCmd.PersistentFlags().StringVar(&flags.home, "home", "", "where scaffold keeps its config and cache, overrides SCAFFOLD_HOME, XDG_CONFIG_HOME and XDG_CACHE_HOME")
+	rootCmd.PersistentFlags().DurationVar(&flags.cacheTTL, "cache-ttl", fetcher.DefaultTTL, "how long the cached registries are used before they're updated")
+	// The persistent flags are needed to find the templates, before cobra knows about the template commands and their flags
+	rootCmd.FParseErrWhitelist.UnknownFlags = true
+	if err := rootCmd.ParseFlags(os.Args[1:]); err != nil && !errors.Is(err, pflag.ErrHelp) {
 		return err
 	}
+	rootCmd.FParseErrWhitelist.UnknownFlags = false
+
+	if err := flags.loadConfig(rootCmd); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
 
-	subCommands, err := getScaffoldCommands(&registryPath, &forceCacheUpdate)
+	availableTemplates, err := availableTemplates(&flags)
 	if err != nil {
 		fmt.Printf("failed to setup subcommands: %s\n", err.Error())
 		os.Exit(1)
 	}
+
+	rootCmd.AddCommand(newListCommand(&flags), newShowCommand(&flags, availableTemplates), newDoctorCommand(&flags), newLintCommand(&flags), newSchemaCommand())
+
+	subCommands := getScaffoldCommands(&flags, availableTemplates, reservedNames(rootCmd))
 	if len(subCommands) > 0 {
 		rootCmd.AddCommand(subCommands...)
 	}
@@ -56,31 +175,34 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
-func runScaffold(ctx context.Context, registryPath *string, forceCacheUpdate *bool) error {
-	ui := slog.New(devslog.NewHandler(os.Stderr, &devslog.Options{
-		HandlerOptions: &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		},
-	}))
-	fetcher := fetcher.NewFetcher(*forceCacheUpdate)
+func runScaffold(ctx context.Context, flags *rootFlags) error {
+	ui := flags.logger()
+	fetcher := flags.fetcher()
 	templateIndexer := templates.NewTemplateIndexer()
 	templateLoader := templates.NewTemplateLoader(ui)
 	fileWriter := templates.NewFileWriter().WithPromptOverride(promptOverrideFile)
+	hookRunner := flags.hookRunner()
 
-	if err := fetcher.CloneRepository(ctx, registryPath, ui); err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
+	sources, err := fetcher.Sources(flags.registryPath)
+	if err != nil {
+		return fmt.Errorf("failed to find registries: %w", err)
 	}
 
-	templates, err := templateIndexer.Index(ctx, *registryPath, ui)
+	availableTemplates, err := templateIndexer.IndexSources(ctx, sources, ui)
 	if err != nil {
 		return fmt.Errorf("failed to index templates: %w", err)
 	}
+	availableTemplates = projectTemplates(flags.project, availableTemplates)
 
-	template, err := chooseTemplate(templates)
+	template, err := chooseTemplate(availableTemplates, templateIndexer.Diagnostics())
 	if err != nil {
 		return fmt.Errorf("failed to choose a template: %w", err)
 	}
 
+	if err := template.CheckUsable(ui, flags.version); err != nil {
+		return err
+	}
+
 	ui.Info("Loading template files", "name", template.File.Name)
 
 	files, err := templateLoader.Load(ctx, template)
@@ -102,15 +224,72 @@ func runScaffold(ctx context.Context, registryPath *string, forceCacheUpdate *bo
 		return fmt.Errorf("failed to template files: %w", err)
 	}
 
+	usedTemplates, err := templateLoader.ResolveDependencies(template, scaffoldDest)
+	if err != nil {
+		return fmt.Errorf("failed to template the used templates: %w", err)
+	}
+
+	dependencyFiles, err := templateLoader.TemplateUsed(ctx, template, usedTemplates)
+	if err != nil {
+		return fmt.Errorf("failed to template the used templates: %w", err)
+	}
+	templatedFiles = append(templatedFiles, dependencyFiles...)
+
 	ui.Info("Templated files", "files", len(templatedFiles))
 
+	hooks, err := hookRunner.PlanAll(ui, template, scaffoldDest, usedTemplates)
+	if err != nil {
+		return fmt.Errorf("failed to prepare hooks: %w", err)
+	}
+
+	if err := hookRunner.RunAll(ctx, ui, templates.HookStagePre, hooks); err != nil {
+		return fmt.Errorf("failed to run pre hooks: %w", err)
+	}
+
 	if err := fileWriter.Write(ctx, ui, templatedFiles); err != nil {
 		return fmt.Errorf("failed to write files: %w", err)
 	}
 
+	if err := hookRunner.RunAll(ctx, ui, templates.HookStagePost, hooks); err != nil {
+		return fmt.Errorf("failed to run post hooks: %w", err)
+	}
+
 	return nil
 }
 
+func promptConfirmHooks(template *templates.Template, hooks templates.TemplateHooks) (bool, error) {
+	theme := huh.ThemeBase16()
+	theme.FieldSeparator = lipgloss.NewStyle().SetString("\n")
+	theme.Help.FullKey.MarginTop(1)
+
+	var sb strings.Builder
+	for _, command := range hooks.Pre {
+		sb.WriteString(fmt.Sprintf("pre:  %s\n", command))
+	}
+	for _, command := range hooks.Post {
+		sb.WriteString(fmt.Sprintf("post: %s\n", command))
+	}
+
+	confirm := false
+	f := huh.
+		NewForm(
+			huh.
+				NewGroup(
+					huh.
+						NewConfirm().
+						Title(fmt.Sprintf("Template: %s wants to run hooks, run them?", template.File.Name)).
+						Description(sb.String()).
+						Value(&confirm),
+				),
+		).
+		WithTheme(theme)
+	if err := f.Run(); err != nil {
+		return false, fmt.Errorf("failed to confirm hooks: %w", err)
+	}
+
+	return confirm, nil
+}
+
 func promptOverrideFile(file templates.TemplatedFile) (bool, error) {
 	theme := huh.ThemeBase16()
 	theme.FieldSeparator = lipgloss.NewStyle().SetString("\n")
@@ -202,6 +381,9 @@ func promptInput(template *templates.Template, files []templates.File) (string,
 
 							for _, file := range files {
 								previewFilePath := path.Join(scaffoldDest, file.RelPath)
+								if mode := template.FileConfig(file.RelPath).Mode; mode != "" {
+									previewFilePath = fmt.Sprintf("%s (%s)", previewFilePath, mode)
+								}
 
 								if _, err := sb.WriteString(fmt.Sprintf("%s\n", previewFilePath)); err != nil {
 									panic(err)
@@ -225,28 +407,122 @@ func promptInput(template *templates.Template, files []templates.File) (string,
 	return scaffoldDest, nil
 }
 
-func chooseTemplate(templates []templates.Template) (*templates.Template, error) {
-	idx, err := fuzzyfinder.Find(
-		templates,
-		func(i int) string {
-			return templates[i].File.Name
-		},
+func chooseTemplate(availableTemplates []templates.Template, diagnostics []templates.Diagnostic) (*templates.Template, error) {
+	options := []fuzzyfinder.Option{
 		fuzzyfinder.WithPreviewWindow(func(i, w, h int) string {
 			if i == -1 {
 				return ""
 			}
 
-			template := templates[i]
+			template := availableTemplates[i]
 			templateContent, err := yaml.Marshal(template.File)
 			if err != nil {
 				return fmt.Sprintf("failed to format template: %s", err.Error())
 			}
 
-			return fmt.Sprintf("Template:\n===\n%s\n===\n", string(templateContent))
-		}))
+			return fmt.Sprintf("%s===\n%s\n===\n", templatePreview(&template), string(templateContent))
+		}),
+	}
+
+	// The broken templates are left out of the picker, so it is pointed out that some are missing
+	if skipped := templates.SkippedTemplates(diagnostics); skipped > 0 {
+		options = append(options, fuzzyfinder.WithHeader(fmt.Sprintf("%d templates could not be loaded, run scaffold doctor for details", skipped)))
+	}
+
+	idx, err := fuzzyfinder.Find(
+		availableTemplates,
+		func(i int) string {
+			return availableTemplates[i].FullName()
+		},
+		options...,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find a template: %w", err)
 	}
 
-	return &templates[idx], nil
+	return &availableTemplates[idx], nil
+}
+
+// templatePreview is the summary of the template shown above its definition in the picker
+func templatePreview(template *templates.Template) string {
+	var preview strings.Builder
+
+	fmt.Fprintf(&preview, "Template: %s\n", template.FullName())
+	if template.File.Deprecated {
+		if template.File.Replacement != "" {
+			fmt.Fprintf(&preview, "DEPRECATED: use %s instead\n", template.File.Replacement)
+		} else {
+			fmt.Fprintln(&preview, "DEPRECATED")
+		}
+	}
+	if template.File.Description != "" {
+		fmt.Fprintf(&preview, "Description: %s\n", template.File.Description)
+	}
+	if template.File.Version != "" {
+		fmt.Fprintf(&preview, "Version: %s\n", template.File.Version)
+	}
+	if len(template.File.Owners) > 0 {
+		fmt.Fprintf(&preview, "Owners: %s\n", strings.Join(template.File.Owners, ", "))
+	}
+	if len(template.File.Tags) > 0 {
+		fmt.Fprintf(&preview, "Tags: %s\n", strings.Join(template.File.Tags, ", "))
+	}
+	if template.File.MinScaffoldVersion != "" {
+		fmt.Fprintf(&preview, "Requires scaffold: %s\n", template.File.MinScaffoldVersion)
+	}
+	fmt.Fprintf(&preview, "Registry: %s\nCommit: %s\n", template.Registry, template.Commit)
+
+	return preview.String()
+}
+
+// reservedNames are the names of the built in commands, which templates can't shadow
+func reservedNames(rootCmd *cobra.Command) map[string]bool {
+	reserved := map[string]bool{"help": true, "completion": true}
+	for _, cmd := range rootCmd.Commands() {
+		reserved[cmd.Name()] = true
+		for _, alias := range cmd.Aliases {
+			reserved[alias] = true
+		}
+	}
+
+	return reserved
+}
+
+// projectTemplates restricts the templates to the ones offered in the project, and sets the default input values of the project
+func projectTemplates(project config.Project, availableTemplates []templates.Template) []templates.Template {
+	offeredTemplates := make([]templates.Template, 0, len(availableTemplates))
+	for _, template := range availableTemplates {
+		if !project.Offers(template.FullName(), template.File.Name) {
+			continue
+		}
+
+		for name, value := range project.InputDefaults(template.FullName(), template.File.Name) {
+			input, ok := template.File.Input[name]
+			if !ok {
+				continue
+			}
+
+			input.Default = value
+			template.File.Input[name] = input
+		}
+
+		offeredTemplates = append(offeredTemplates, template)
+	}
+
+	return offeredTemplates
+}
+
+// indexRegistries indexes the templates of all the registries, namespacing them by the registry they come from
+func indexRegistries(ctx context.Context, templateIndexer *templates.TemplateIndexer, registries []fetcher.LocalRegistry, ui *slog.Logger) ([]templates.Template, error) {
+	availableTemplates := make([]templates.Template, 0)
+	for _, registry := range registries {
+		registryTemplates, err := templateIndexer.IndexRegistry(ctx, registry, ui)
+		if err != nil {
+			return nil, fmt.Errorf("registry: %s, %w", registry.Name, err)
+		}
+
+		availableTemplates = append(availableTemplates, registryTemplates...)
+	}
+
+	return templateIndexer.Resolve(availableTemplates), nil
 }
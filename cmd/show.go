@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kjuulh/scaffold/internal/templates"
+)
+
+func newShowCommand(flags *rootFlags, availableTemplates []templates.Template) *cobra.Command {
+	var render bool
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "show a template, and the files it would scaffold, without writing anything",
+	}
+
+	cmd.PersistentFlags().BoolVar(&render, "render", false, "print the rendered content of the files as well")
+
+	ui := flags.logger()
+	templateLoader := templates.NewTemplateLoader(ui)
+
+	cmd.AddCommand(templateCommands(ui, availableTemplates, nil, func(cmd *cobra.Command, template *templates.Template, templatePath string) error {
+		files, err := templateLoader.Load(cmd.Context(), template)
+		if err != nil {
+			return fmt.Errorf("failed to load template files: %w", err)
+		}
+
+		templatedFiles, err := templateLoader.TemplateFiles(template, files, templatePath)
+		if err != nil {
+			return fmt.Errorf("failed to template files: %w", err)
+		}
+
+		dependencyFiles, err := templateLoader.TemplateDependencies(cmd.Context(), template, templatePath)
+		if err != nil {
+			return fmt.Errorf("failed to template the used templates: %w", err)
+		}
+		templatedFiles = append(templatedFiles, dependencyFiles...)
+
+		return showTemplate(cmd.OutOrStdout(), template, templatePath, templatedFiles, render)
+	})...)
+
+	return cmd
+}
+
+// showTemplate prints the metadata and inputs of the template, and the files it would write, optionally with their content
+func showTemplate(out io.Writer, template *templates.Template, templatePath string, templatedFiles []templates.TemplatedFile, render bool) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "Name:\t%s\n", template.FullName())
+	if template.File.Description != "" {
+		fmt.Fprintf(writer, "Description:\t%s\n", template.File.Description)
+	}
+	if len(template.File.Tags) > 0 {
+		fmt.Fprintf(writer, "Tags:\t%s\n", strings.Join(template.File.Tags, ", "))
+	}
+	if template.File.Version != "" {
+		fmt.Fprintf(writer, "Version:\t%s\n", template.File.Version)
+	}
+	if len(template.File.Owners) > 0 {
+		fmt.Fprintf(writer, "Owners:\t%s\n", strings.Join(template.File.Owners, ", "))
+	}
+	if template.File.Deprecated {
+		fmt.Fprintf(writer, "Deprecated:\t%s\n", deprecationNote(template.File.Replacement))
+	}
+	if template.File.MinScaffoldVersion != "" {
+		fmt.Fprintf(writer, "Requires scaffold:\t%s\n", template.File.MinScaffoldVersion)
+	}
+	if template.Base != nil {
+		bases := make([]string, 0)
+		for base := template.Base; base != nil; base = base.Base {
+			bases = append(bases, base.FullName())
+		}
+
+		fmt.Fprintf(writer, "Extends:\t%s\n", strings.Join(bases, " -> "))
+	}
+	if len(template.Dependencies) > 0 {
+		uses := make([]string, 0, len(template.Dependencies))
+		for _, dependency := range template.Dependencies {
+			uses = append(uses, dependency.FullName())
+		}
+
+		fmt.Fprintf(writer, "Uses:\t%s\n", strings.Join(uses, ", "))
+	}
+	if template.Category != "" {
+		fmt.Fprintf(writer, "Category:\t%s\n", template.Category)
+	}
+	if template.Registry != "" {
+		fmt.Fprintf(writer, "Registry:\t%s\n", template.Registry)
+	}
+	if template.Commit != "" {
+		fmt.Fprintf(writer, "Commit:\t%s\n", template.Commit)
+	}
+	fmt.Fprintf(writer, "Path:\t%s\n", template.Path)
+	fmt.Fprintf(writer, "Destination:\t%s\n", templatePath)
+
+	if len(template.Input) > 0 {
+		names := make([]string, 0, len(template.Input))
+		for name := range template.Input {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(writer, "\nInputs:")
+		for _, name := range names {
+			fmt.Fprintf(writer, "  %s\t%s\t%s\n", name, template.Input[name], template.File.Input[name].Description)
+		}
+	}
+
+	sort.SliceStable(templatedFiles, func(i, j int) bool {
+		return templatedFiles[i].DestinationPath < templatedFiles[j].DestinationPath
+	})
+
+	fmt.Fprintln(writer, "\nFiles:")
+	for _, file := range templatedFiles {
+		if file.Template != "" && file.Template != template.FullName() {
+			fmt.Fprintf(writer, "  %s\t%s\tfrom %s\n", file.DestinationPath, file.Describe(), file.Template)
+			continue
+		}
+
+		fmt.Fprintf(writer, "  %s\t%s\n", file.DestinationPath, file.Describe())
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if !render {
+		return nil
+	}
+
+	for _, file := range templatedFiles {
+		fmt.Fprintf(out, "\n--- %s (%s)\n", file.DestinationPath, file.Describe())
+		if _, err := out.Write(file.Content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
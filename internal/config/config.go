@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the name of the global config in the config dir
+const ConfigFile = "config.yaml"
+
+// ProjectFile is the name of the project config, it is found in the working directory or any of its parents
+const ProjectFile = ".scaffold.yaml"
+
+// Config is the global config of the user, the environment variables and flags take precedence over it
+type Config struct {
+	// Registries are used alongside SCAFFOLD_REGISTRY and SCAFFOLD_REGISTRIES, the url can be pinned using url@ref
+	Registries []Registry `yaml:"registries"`
+	// CacheTTL is how long the cached registries are used before they're updated, i.e. 24h
+	CacheTTL time.Duration `yaml:"cacheTTL"`
+	Offline  bool          `yaml:"offline"`
+	UI       UI            `yaml:"ui"`
+}
+
+type Registry struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+type UI struct {
+	// LogLevel is one of debug, info, warn or error
+	LogLevel string `yaml:"logLevel"`
+	NoColor  bool   `yaml:"noColor"`
+}
+
+// Level returns the log level, defaulting to info
+func (u UI) Level() (slog.Level, error) {
+	if u.LogLevel == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(u.LogLevel)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level: %s, expected debug, info, warn or error", u.LogLevel)
+	}
+
+	return level, nil
+}
+
+// Load reads the global config from the config dir, a missing config is the same as an empty one
+func Load(configDir string) (Config, error) {
+	var config Config
+
+	configPath := path.Join(configDir, ConfigFile)
+	if err := readYAML(configPath, &config); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Config{}, nil
+		}
+
+		return Config{}, err
+	}
+
+	if _, err := config.UI.Level(); err != nil {
+		return Config{}, fmt.Errorf("config: %s, %w", configPath, err)
+	}
+
+	return config, nil
+}
+
+// Project is the config of a repository, it is checked in next to the code so everyone scaffolding in the repository gets the same templates
+type Project struct {
+	// Dir is the folder of the project config
+	Dir string `yaml:"-"`
+	// Registry pins the registry used in the project, like --registry. A relative path is relative to the project config
+	Registry string `yaml:"registry"`
+	// Templates restricts which templates are offered, by their name or full name, i.e. default/go/http/externalhttp. Globs like team/* match a single level, team/** matches the templates in nested categories as well, i.e. team/go/http/externalhttp. All templates are offered if empty
+	Templates []string `yaml:"templates"`
+	// Inputs are the default input values per template, by their name or full name
+	Inputs map[string]map[string]string `yaml:"inputs"`
+}
+
+// LoadProject finds the project config in dir or its parents, stopping at the root of the repository. A missing project config is the same as an empty one
+func LoadProject(dir string) (Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Project{}, fmt.Errorf("failed to find project dir: %s, %w", dir, err)
+	}
+
+	for {
+		var project Project
+		err := readYAML(filepath.Join(dir, ProjectFile), &project)
+		if err == nil {
+			project.Dir = dir
+			if err := project.validate(); err != nil {
+				return Project{}, fmt.Errorf("config: %s, %w", filepath.Join(dir, ProjectFile), err)
+			}
+
+			return project, nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return Project{}, err
+		}
+
+		// The project config doesn't apply outside of its repository
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return Project{}, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Project{}, nil
+		}
+
+		dir = parent
+	}
+}
+
+func (p Project) validate() error {
+	for _, pattern := range p.Templates {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid template pattern: %s, %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// RegistryPath returns the registry pinned by the project, with a relative path resolved from the project config
+func (p Project) RegistryPath() string {
+	registry := p.Registry
+	if registry == "" || filepath.IsAbs(registry) || strings.Contains(registry, "://") || strings.HasPrefix(registry, "git@") {
+		return registry
+	}
+
+	return filepath.Join(p.Dir, registry)
+}
+
+// Offers returns whether a template is offered in the project
+func (p Project) Offers(fullName string, name string) bool {
+	if len(p.Templates) == 0 {
+		return true
+	}
+
+	for _, pattern := range p.Templates {
+		if matchPattern(pattern, fullName) || matchPattern(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPattern matches the name against the pattern one path segment at a time with path.Match, where a ** segment matches any number of segments
+func matchPattern(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := range len(name) + 1 {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+
+	if matched, _ := path.Match(pattern[0], name[0]); !matched {
+		return false
+	}
+
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// InputDefaults returns the default input values of a template, the ones given for its full name take precedence over its name
+func (p Project) InputDefaults(fullName string, name string) map[string]string {
+	defaults := make(map[string]string)
+	for key, value := range p.Inputs[name] {
+		defaults[key] = value
+	}
+
+	for key, value := range p.Inputs[fullName] {
+		defaults[key] = value
+	}
+
+	return defaults
+}
+
+func readYAML(filePath string, out any) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %s, %w", filePath, err)
+	}
+
+	if err := yaml.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("failed to parse config: %s, %w", filePath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	configDir := t.TempDir()
+
+	config, err := Load(configDir)
+	require.NoError(t, err, "a missing config is empty")
+	assert.Equal(t, Config{}, config)
+
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, ConfigFile), []byte(`
+registries:
+  - name: team
+    url: git@github.com:team/scaffold-registry.git@v1
+cacheTTL: 24h
+ui:
+  logLevel: debug
+  noColor: true
+`), 0o644))
+
+	config, err = Load(configDir)
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Registries: []Registry{{Name: "team", URL: "git@github.com:team/scaffold-registry.git@v1"}},
+		CacheTTL:   24 * time.Hour,
+		UI:         UI{LogLevel: "debug", NoColor: true},
+	}, config)
+
+	level, err := config.UI.Level()
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, ConfigFile), []byte("ui:\n  logLevel: loud\n"), 0o644))
+	_, err = Load(configDir)
+	assert.ErrorContains(t, err, "invalid log level: loud")
+}
+
+func TestLoadProject(t *testing.T) {
+	outside := t.TempDir()
+	repository := filepath.Join(outside, "repository")
+	service := filepath.Join(repository, "services", "api")
+	require.NoError(t, os.MkdirAll(service, 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(repository, ".git"), 0o755))
+
+	require.NoError(t, os.WriteFile(filepath.Join(outside, ProjectFile), []byte("registry: ./outside\n"), 0o644))
+
+	project, err := LoadProject(service)
+	require.NoError(t, err)
+	assert.Equal(t, Project{}, project, "the project config doesn't apply outside of the repository")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repository, ProjectFile), []byte(`
+registry: ../scaffold-registry
+templates:
+  - team/*
+  - company/**/http/*
+  - externalhttp
+inputs:
+  externalhttp:
+    package: api
+    module: github.com/company/api
+  default/externalhttp:
+    package: http
+`), 0o644))
+
+	project, err = LoadProject(service)
+	require.NoError(t, err)
+	assert.Equal(t, repository, project.Dir)
+	assert.Equal(t, filepath.Join(outside, "scaffold-registry"), project.RegistryPath())
+
+	assert.True(t, project.Offers("team/service", "service"))
+	assert.True(t, project.Offers("default/externalhttp", "externalhttp"))
+	assert.False(t, project.Offers("default/service", "service"))
+	assert.False(t, project.Offers("team/go/service", "service"), "* doesn't match nested categories")
+	assert.True(t, project.Offers("company/go/http/client", "client"), "** matches nested categories")
+	assert.True(t, project.Offers("company/http/client", "client"), "** matches no category as well")
+	assert.False(t, project.Offers("company/go/grpc/client", "client"))
+
+	assert.Equal(t, map[string]string{"package": "http", "module": "github.com/company/api"}, project.InputDefaults("default/externalhttp", "externalhttp"))
+	assert.Equal(t, map[string]string{}, project.InputDefaults("default/service", "service"))
+
+	assert.True(t, Project{}.Offers("default/service", "service"), "all templates are offered without restrictions")
+	assert.Equal(t, "https://github.com/kjuulh/scaffold.git", Project{Dir: repository, Registry: "https://github.com/kjuulh/scaffold.git"}.RegistryPath())
+
+	require.NoError(t, os.WriteFile(filepath.Join(repository, ProjectFile), []byte("templates:\n  - '[team'\n"), 0o644))
+	_, err = LoadProject(service)
+	assert.ErrorContains(t, err, "invalid template pattern")
+}
@@ -0,0 +1,160 @@
+package fetcher
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func isArchive(registryPath string) bool {
+	for _, suffix := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(registryPath, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extractArchive extracts a tarball or zip archive into dest, replacing anything already there
+func extractArchive(archivePath string, dest string) error {
+	if err := os.RemoveAll(dest); err != nil {
+		return fmt.Errorf("failed to clean archive destination: %s, %w", dest, err)
+	}
+
+	if err := os.MkdirAll(dest, readWriteExec); err != nil {
+		return fmt.Errorf("failed to create archive destination: %s, %w", dest, err)
+	}
+
+	if strings.HasSuffix(archivePath, ".zip") {
+		return extractZip(archivePath, dest)
+	}
+
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %s, %w", archivePath, err)
+	}
+	defer archive.Close()
+
+	var reader io.Reader = archive
+	if !strings.HasSuffix(archivePath, ".tar") {
+		gzipReader, err := gzip.NewReader(archive)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip archive: %s, %w", archivePath, err)
+		}
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	}
+
+	return extractTar(reader, dest)
+}
+
+func extractTar(reader io.Reader, dest string) error {
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+
+		target, err := archiveTarget(dest, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, readWriteExec); err != nil {
+				return fmt.Errorf("failed to create dir: %s, %w", target, err)
+			}
+		case tar.TypeReg:
+			if err := writeArchiveFile(target, tarReader, header.FileInfo().Mode()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(archivePath string, dest string) error {
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open zip archive: %s, %w", archivePath, err)
+	}
+	defer zipReader.Close()
+
+	for _, file := range zipReader.File {
+		target, err := archiveTarget(dest, file.Name)
+		if err != nil {
+			return err
+		}
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, readWriteExec); err != nil {
+				return fmt.Errorf("failed to create dir: %s, %w", target, err)
+			}
+			continue
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read zip entry: %s, %w", file.Name, err)
+		}
+
+		err = writeArchiveFile(target, content, file.Mode())
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveTarget makes sure an archive entry can't be written outside of dest
+func archiveTarget(dest string, name string) (string, error) {
+	target := filepath.Join(dest, name)
+	if target != filepath.Clean(dest) && !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry: %s points outside of the archive", name)
+	}
+
+	return target, nil
+}
+
+func writeArchiveFile(target string, content io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), readWriteExec); err != nil {
+		return fmt.Errorf("failed to create dir for: %s, %w", target, err)
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %s, %w", target, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, content); err != nil {
+		return fmt.Errorf("failed to write file: %s, %w", target, err)
+	}
+
+	return nil
+}
+
+// archiveRoot steps into the single top level folder most archives of a repository have
+func archiveRoot(dest string) string {
+	entries, err := os.ReadDir(dest)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dest
+	}
+
+	return filepath.Join(dest, entries[0].Name())
+}
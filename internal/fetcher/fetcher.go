@@ -2,118 +2,436 @@ package fetcher
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/kjuulh/scaffold/internal/home"
 )
 
-// Fetcher allows pulling from an upstream scaffold registry. This is hard coded to the lunarway/scaffold registry, it can also be provided by a path which in that case, will not do anything
+// Fetcher allows pulling from upstream scaffold registries. The registries are configured using SCAFFOLD_REGISTRY and SCAFFOLD_REGISTRIES, it can also be provided by a path which in that case, will not do anything
 type Fetcher struct {
 	ignoreCache bool
+	ttl         time.Duration
+	offline     bool
+	httpTimeout time.Duration
+	git         GitBackend
+	cacheDir    string
+	registries  []Registry
 }
 
+// DefaultTTL is how long a cached registry is used before it is updated
+const DefaultTTL = time.Hour * 24 * 7
+
+// DefaultHTTPTimeout is how long a request to an http registry may take, including reading the archive, before it is given up on
+const DefaultHTTPTimeout = time.Minute * 2
+
 func NewFetcher(ignoreCache bool) *Fetcher {
 	return &Fetcher{
 		ignoreCache: ignoreCache,
+		ttl:         DefaultTTL,
+		offline:     false,
+		httpTimeout: DefaultHTTPTimeout,
+		git:         NewGitBackend(),
+		cacheDir:    home.Resolve("").Cache,
 	}
 }
 
+// WithTTL sets how long the cached registries are used, before they're updated
+func (f *Fetcher) WithTTL(ttl time.Duration) *Fetcher {
+	f.ttl = ttl
+
+	return f
+}
+
+// WithOffline makes the fetcher never touch the network, only registries which are already cached can be used
+func (f *Fetcher) WithOffline(offline bool) *Fetcher {
+	f.offline = offline
+
+	return f
+}
+
+// WithHTTPTimeout sets how long a request to an http registry may take, so an unresponsive server doesn't hang scaffold
+func (f *Fetcher) WithHTTPTimeout(timeout time.Duration) *Fetcher {
+	f.httpTimeout = timeout
+
+	return f
+}
+
+// WithGitBackend sets how git registries are cloned and updated
+func (f *Fetcher) WithGitBackend(git GitBackend) *Fetcher {
+	f.git = git
+
+	return f
+}
+
+// WithCacheDir sets the folder the registries are cached in, see home.Resolve
+func (f *Fetcher) WithCacheDir(dir string) *Fetcher {
+	f.cacheDir = dir
+
+	return f
+}
+
+// WithRegistries sets the registries from the config, SCAFFOLD_REGISTRY and SCAFFOLD_REGISTRIES take precedence over them
+func (f *Fetcher) WithRegistries(registries []Registry) *Fetcher {
+	f.registries = registries
+
+	return f
+}
+
 const readWriteExec = 0o744
 
-var (
-	scaffoldFolder   = os.ExpandEnv("$HOME/.scaffold")
-	scaffoldClone    = path.Join(scaffoldFolder, "upstream")
-	scaffoldRegistry = path.Join(scaffoldClone, "registry")
-	scaffoldCache    = path.Join(scaffoldFolder, "scaffold.updates.json")
-)
+// DefaultRegistryName is the name of the registry given by SCAFFOLD_REGISTRY
+const DefaultRegistryName = "default"
 
-func (f *Fetcher) Available(registryPath *string) (string, bool) {
-	if *registryPath == "" {
-		if _, err := os.Stat(scaffoldRegistry); err != nil {
-			return "", false
-		}
+// Registry is an upstream git repository containing templates in its registry folder, optionally pinned to a ref
+type Registry struct {
+	Name string
+	URL  string
+	// Ref is a branch, tag or commit, given as url@ref
+	Ref string
+}
+
+// String is the name of the registry, or its url when it has no name, i.e. the default registry
+func (r Registry) String() string {
+	if r.Name == "" {
+		return r.URL
+	}
+
+	return r.Name
+}
 
-		// *registryPath = scaffoldRegistry
+// LocalRegistry is a registry which is available on disk, its name is used to namespace its templates
+type LocalRegistry struct {
+	Name string
+	Path string
+	// Ref and Commit are set for git registries, Commit is what the registry resolved to, which makes a run reproducible
+	Ref    string
+	Commit string
+}
 
-		return scaffoldRegistry, true
+// ParseRegistryURL splits a url@ref into the url and ref. A ref containing slashes requires the url to end in .git, as in https://github.com/org/registry.git@feature/x
+func ParseRegistryURL(source string) (string, string) {
+	index := strings.LastIndex(source, "@")
+	if index == -1 {
+		return source, ""
+	}
+
+	url, ref := source[:index], source[index+1:]
+	if ref == "" {
+		return source, ""
+	}
 
+	// The @ in git@github.com:org/registry.git is part of the url
+	if !strings.ContainsAny(url, "/:") || (strings.ContainsAny(ref, "/:") && !strings.HasSuffix(url, ".git")) {
+		return source, ""
 	}
 
-	return "", false
+	return url, ref
 }
 
-func (f *Fetcher) CloneRepository(ctx context.Context, registryPath *string, ui *slog.Logger) error {
-	if err := os.MkdirAll(scaffoldFolder, readWriteExec); err != nil {
-		return fmt.Errorf("failed to create scaffold folder: %w", err)
+// clonePath is where the registry is cloned to, the default registry keeps the original upstream folder, and unnamed registries given by --registry are cached by their url
+func (f *Fetcher) clonePath(r Registry) string {
+	switch r.Name {
+	case DefaultRegistryName:
+		return path.Join(f.cacheDir, "upstream")
+	case "":
+		return f.unnamedPath(r.URL)
+	default:
+		return path.Join(f.cacheDir, "registries", r.Name)
 	}
+}
 
-	if *registryPath == "" {
-		// update the registry path as it is shared
-		*registryPath = scaffoldRegistry
-		if _, err := os.Stat(scaffoldClone); err != nil {
-			if !errors.Is(err, os.ErrNotExist) {
-				return fmt.Errorf("failed to find the upstream folder: %s, %w", scaffoldClone, err)
-			}
+// unnamedPath is where a registry given by --registry is cached, by the url or path it is given by
+func (f *Fetcher) unnamedPath(source string) string {
+	return path.Join(f.cacheDir, "cache", cacheKey(source))
+}
 
-			ui.Info("cloning upstream templates")
-			if err := cloneUpstream(ctx); err != nil {
-				return fmt.Errorf("failed to clone upstream registry: %w", err)
-			}
-		} else {
-			now := time.Now()
-			lastUpdatedUnix := getCacheUpdate(ui, ctx)
-			lastUpdated := time.Unix(lastUpdatedUnix, 0)
-
-			// Cache for 7 days
-			if lastUpdated.Before(now.Add(-time.Hour*24*7)) || f.ignoreCache {
-				ui.Info("update templates folder")
-				if err := f.UpdateUpstream(ctx); err != nil {
-					return fmt.Errorf("failed to update upstream scaffold folder: %w", err)
-				}
-			}
+func (f *Fetcher) local(ctx context.Context, r Registry) (LocalRegistry, error) {
+	commit, err := f.git.Head(ctx, f.clonePath(r))
+	if err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to resolve commit of registry: %s, %w", r.Name, err)
+	}
+
+	return LocalRegistry{
+		Name:   r.Name,
+		Path:   registryFolder(f.clonePath(r)),
+		Ref:    r.Ref,
+		Commit: commit,
+	}, nil
+}
+
+func (f *Fetcher) cachePath(r Registry) string {
+	if r.Name == DefaultRegistryName {
+		return path.Join(f.cacheDir, "scaffold.updates.json")
+	}
+
+	return fmt.Sprintf("%s.updates.json", f.clonePath(r))
+}
+
+func cacheKey(source string) string {
+	hash := sha256.Sum256([]byte(source))
+
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// Registries returns the configured registries, the ones given by WithRegistries are overridden by the environment. SCAFFOLD_REGISTRY is the default registry, and SCAFFOLD_REGISTRIES is a comma separated list of name=url pairs. Both can be pinned using url@ref
+func (f *Fetcher) Registries() ([]Registry, error) {
+	registries := make([]Registry, 0, len(f.registries))
+	for _, registry := range f.registries {
+		if err := validateRegistryName(registry.Name); err != nil {
+			return nil, err
 		}
+
+		if slices.ContainsFunc(registries, func(r Registry) bool { return r.Name == registry.Name }) {
+			return nil, fmt.Errorf("registry: '%s' is configured more than once", registry.Name)
+		}
+
+		registries = append(registries, registry)
+	}
+
+	// The environment replaces a configured registry of the same name
+	setRegistry := func(registry Registry) {
+		index := slices.IndexFunc(registries, func(r Registry) bool { return r.Name == registry.Name })
+		if index == -1 {
+			registries = append(registries, registry)
+			return
+		}
+
+		registries[index] = registry
+	}
+
+	environmentNames := make(map[string]bool)
+
+	defaultURL, defaultRef := ParseRegistryURL(os.Getenv("SCAFFOLD_REGISTRY"))
+	defaultRegistry := Registry{Name: DefaultRegistryName, URL: defaultURL, Ref: defaultRef}
+	if defaultRegistry.URL != "" {
+		setRegistry(defaultRegistry)
+		environmentNames[DefaultRegistryName] = true
+	} else if _, err := os.Stat(f.clonePath(defaultRegistry)); err == nil && !slices.ContainsFunc(registries, func(r Registry) bool { return r.Name == DefaultRegistryName }) {
+		// The default registry has been cloned previously, and doesn't need the url until it is removed
+		registries = slices.Insert(registries, 0, defaultRegistry)
+	}
+
+	namedRegistries := os.Getenv("SCAFFOLD_REGISTRIES")
+	if namedRegistries == "" {
+		return registries, nil
+	}
+
+	for _, namedRegistry := range strings.Split(namedRegistries, ",") {
+		namedRegistry = strings.TrimSpace(namedRegistry)
+		if namedRegistry == "" {
+			continue
+		}
+
+		name, url, ok := strings.Cut(namedRegistry, "=")
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("invalid registry in SCAFFOLD_REGISTRIES: '%s', expected name=url", namedRegistry)
+		}
+
+		if err := validateRegistryName(name); err != nil {
+			return nil, fmt.Errorf("invalid registry in SCAFFOLD_REGISTRIES: %w", err)
+		}
+
+		if environmentNames[name] {
+			return nil, fmt.Errorf("registry: '%s' is configured more than once", name)
+		}
+		environmentNames[name] = true
+
+		url, ref := ParseRegistryURL(url)
+		setRegistry(Registry{Name: name, URL: url, Ref: ref})
+	}
+
+	return registries, nil
+}
+
+func validateRegistryName(name string) error {
+	if name == "" {
+		return errors.New("registry name is required")
+	}
+
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid registry name: '%s', it cannot contain slashes", name)
 	}
 
 	return nil
 }
 
-func (f *Fetcher) UpdateUpstream(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "git", "pull", "--rebase")
-	cmd.Dir = scaffoldClone
+// Available returns the registries which are already on disk, without fetching anything
+func (f *Fetcher) Available(registryPath string) []LocalRegistry {
+	sources, err := f.Sources(registryPath)
+	if err != nil {
+		return nil
+	}
+
+	localRegistries := make([]LocalRegistry, 0, len(sources))
+	for _, source := range sources {
+		localRegistry, err := source.Cached(context.Background())
+		if err != nil {
+			continue
+		}
+
+		localRegistries = append(localRegistries, localRegistry)
+	}
+
+	return localRegistries
+}
+
+// isGitURL decides by the form of the url whether it is a git repository, a remote url or a path ending in .git, optionally as a file:// url. The disk isn't looked at, so a registry keeps its source when the repository is gone, and its cached clone is used offline
+func isGitURL(registryPath string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "git@"} {
+		if strings.HasPrefix(registryPath, prefix) {
+			return true
+		}
+	}
+
+	// A bare repository on disk is cloned like any other remote
+	return strings.HasSuffix(strings.TrimSuffix(registryPath, "/"), ".git")
+}
+
+// registryFolder returns the registry folder of a repository, or the folder itself if it contains the templates directly
+func registryFolder(dir string) string {
+	registry := path.Join(dir, "registry")
+	if info, err := os.Stat(registry); err == nil && info.IsDir() {
+		return registry
+	}
+
+	return dir
+}
 
-	output, err := cmd.CombinedOutput()
+// CloneRepository clones the registry if it isn't on disk already, or updates it if the cache has expired. The registry is checked out at its ref, or the default branch. If an update fails the stale cache is used
+func (f *Fetcher) CloneRepository(ctx context.Context, registry Registry, ui *slog.Logger) (LocalRegistry, error) {
+	unlock, err := f.lockRegistry(ctx, ui, registry)
 	if err != nil {
-		fmt.Printf("git pull failed with output: %s\n\n", string(output))
-		return fmt.Errorf("git pull failed: %w", err)
+		return LocalRegistry{}, err
+	}
+	defer unlock()
+
+	clonePath := f.clonePath(registry)
+	if _, err := os.Stat(clonePath); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return LocalRegistry{}, fmt.Errorf("failed to find the upstream folder: %s, %w", clonePath, err)
+		}
+
+		if f.offline {
+			return LocalRegistry{}, fmt.Errorf("registry: %s isn't cached, and scaffold is offline", registry)
+		}
+
+		ui.Info("cloning upstream templates", "registry", registry.Name)
+		if err := f.cloneUpstream(ctx, registry); err != nil {
+			return LocalRegistry{}, fmt.Errorf("failed to clone upstream registry: %w", err)
+		}
+	} else if !f.offline {
+		now := time.Now()
+		lastUpdatedUnix := f.getCacheUpdate(ui, ctx, registry)
+		lastUpdated := time.Unix(lastUpdatedUnix, 0)
+
+		if lastUpdated.Before(now.Add(-f.ttl)) || f.ignoreCache {
+			ui.Info("update templates folder", "registry", registry.Name)
+			if err := f.updateUpstream(ctx, registry); err != nil {
+				ui.Warn("failed to update registry, using the stale cache", "registry", registry.Name, "lastUpdated", lastUpdated, "error", err)
+			}
+		}
+	}
+
+	if err := f.checkoutRef(ctx, registry, ui); err != nil {
+		return LocalRegistry{}, err
 	}
 
-	if err := createCacheUpdate(ctx); err != nil {
+	localRegistry, err := f.local(ctx, registry)
+	if err != nil {
+		return LocalRegistry{}, err
+	}
+
+	ui.Info("using registry", "registry", registry.Name, "ref", registry.Ref, "commit", localRegistry.Commit)
+
+	return localRegistry, nil
+}
+
+// UpdateUpstream fetches the latest changes of the registry, while holding the lock of its cache
+func (f *Fetcher) UpdateUpstream(ctx context.Context, registry Registry) error {
+	unlock, err := f.lockRegistry(ctx, nil, registry)
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	return nil
+	return f.updateUpstream(ctx, registry)
 }
 
-func cloneUpstream(ctx context.Context) error {
-	githubProject := os.Getenv("SCAFFOLD_REGISTRY")
-	if githubProject == "" {
-		return errors.New("failed to find SCAFFOLD_REGISTRY set the environment variable to a fork of https://github.com/kjuulh/scaffold-registry")
+func (f *Fetcher) updateUpstream(ctx context.Context, registry Registry) error {
+	if err := f.git.Fetch(ctx, f.clonePath(registry)); err != nil {
+		return err
+	}
+
+	if err := f.createCacheUpdate(ctx, registry); err != nil {
+		return err
 	}
 
-	cmd := exec.CommandContext(ctx, "git", "clone", githubProject, scaffoldClone)
+	return nil
+}
+
+// checkoutRef checks out the pinned ref of the registry, or the default branch if it isn't pinned. A ref which isn't known locally is fetched, unless offline
+func (f *Fetcher) checkoutRef(ctx context.Context, registry Registry, ui *slog.Logger) error {
+	clonePath := f.clonePath(registry)
+
+	ref := registry.Ref
+	if ref == "" {
+		defaultBranch, err := f.git.DefaultBranch(ctx, clonePath)
+		if err != nil {
+			// Without a known default branch, whatever is checked out is used
+			return nil
+		}
+
+		ref = defaultBranch
+	}
 
-	output, err := cmd.CombinedOutput()
+	commit, err := f.git.Resolve(ctx, clonePath, ref)
 	if err != nil {
-		fmt.Printf("git clone failed with output: %s\n\n", string(output))
-		return fmt.Errorf("git clone failed: %w", err)
+		if f.offline {
+			return fmt.Errorf("registry: %s, ref: %s isn't cached, and scaffold is offline", registry, ref)
+		}
+
+		ui.Info("fetching ref", "registry", registry.Name, "ref", ref)
+		if err := f.git.Fetch(ctx, clonePath); err != nil {
+			return fmt.Errorf("failed to fetch ref: %s, %w", ref, err)
+		}
+
+		commit, err = f.git.Resolve(ctx, clonePath, ref)
+		if err != nil {
+			return fmt.Errorf("registry: %s, %w", registry.Name, err)
+		}
+	}
+
+	if head, err := f.git.Head(ctx, clonePath); err == nil && head == commit {
+		return nil
+	}
+
+	if err := f.git.Checkout(ctx, clonePath, commit); err != nil {
+		return fmt.Errorf("failed to checkout ref: %s, %w", ref, err)
+	}
+
+	return nil
+}
+
+func (f *Fetcher) cloneUpstream(ctx context.Context, registry Registry) error {
+	if registry.URL == "" {
+		return fmt.Errorf("no url for registry: %s, set SCAFFOLD_REGISTRY to a fork of https://github.com/kjuulh/scaffold-registry", registry.Name)
+	}
+
+	if err := f.git.Clone(ctx, registry.URL, f.clonePath(registry)); err != nil {
+		return err
 	}
 
-	if err := createCacheUpdate(ctx); err != nil {
+	if err := f.createCacheUpdate(ctx, registry); err != nil {
 		return err
 	}
 
@@ -124,7 +442,7 @@ type CacheUpdate struct {
 	LastUpdated int64 `json:"lastUpdated"`
 }
 
-func createCacheUpdate(_ context.Context) error {
+func (f *Fetcher) createCacheUpdate(_ context.Context, registry Registry) error {
 	content, err := json.Marshal(CacheUpdate{
 		LastUpdated: time.Now().Unix(),
 	})
@@ -132,15 +450,15 @@ func createCacheUpdate(_ context.Context) error {
 		return fmt.Errorf("failed to prepare cache update: %w", err)
 	}
 
-	if err := os.WriteFile(scaffoldCache, content, readWriteExec); err != nil {
+	if err := writeFileAtomic(f.cachePath(registry), content, readWriteExec); err != nil {
 		return fmt.Errorf("failed to write cache update: %w", err)
 	}
 
 	return nil
 }
 
-func getCacheUpdate(ui *slog.Logger, _ context.Context) int64 {
-	content, err := os.ReadFile(scaffoldCache)
+func (f *Fetcher) getCacheUpdate(ui *slog.Logger, _ context.Context, registry Registry) int64 {
+	content, err := os.ReadFile(f.cachePath(registry))
 	if err != nil {
 		return 0
 	}
@@ -0,0 +1,43 @@
+package fetcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistries(t *testing.T) {
+	t.Setenv("SCAFFOLD_REGISTRY", "")
+	t.Setenv("SCAFFOLD_REGISTRIES", "")
+
+	fetcher := NewFetcher(false).WithCacheDir(t.TempDir()).WithRegistries([]Registry{
+		{Name: DefaultRegistryName, URL: "https://github.com/kjuulh/scaffold.git"},
+		{Name: "team", URL: "git@github.com:team/scaffold-registry.git", Ref: "v1"},
+	})
+
+	registries, err := fetcher.Registries()
+	require.NoError(t, err)
+	assert.Equal(t, []Registry{
+		{Name: DefaultRegistryName, URL: "https://github.com/kjuulh/scaffold.git"},
+		{Name: "team", URL: "git@github.com:team/scaffold-registry.git", Ref: "v1"},
+	}, registries)
+
+	t.Setenv("SCAFFOLD_REGISTRY", "https://github.com/company/scaffold.git@v2")
+	t.Setenv("SCAFFOLD_REGISTRIES", "team=git@github.com:team/scaffold-registry.git@v3,personal=https://github.com/me/scaffold.git")
+
+	registries, err = fetcher.Registries()
+	require.NoError(t, err)
+	assert.Equal(t, []Registry{
+		{Name: DefaultRegistryName, URL: "https://github.com/company/scaffold.git", Ref: "v2"},
+		{Name: "team", URL: "git@github.com:team/scaffold-registry.git", Ref: "v3"},
+		{Name: "personal", URL: "https://github.com/me/scaffold.git"},
+	}, registries, "the environment takes precedence over the config")
+
+	t.Setenv("SCAFFOLD_REGISTRIES", "default=https://github.com/me/scaffold.git")
+	_, err = fetcher.Registries()
+	assert.ErrorContains(t, err, "configured more than once")
+
+	_, err = NewFetcher(false).WithRegistries([]Registry{{Name: "team/a", URL: "https://github.com/me/scaffold.git"}}).Registries()
+	assert.ErrorContains(t, err, "cannot contain slashes")
+}
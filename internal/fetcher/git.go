@@ -0,0 +1,350 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+// GitBackend is what the fetcher uses to clone and update git registries. The pure go backend is used by default, the git binary is kept as a fallback for what it doesn't support, such as credential helpers
+type GitBackend interface {
+	Clone(ctx context.Context, url string, dir string) error
+	// Fetch updates the remote branches and tags of origin
+	Fetch(ctx context.Context, dir string) error
+	// Resolve resolves a branch, tag or commit to a commit. Branches are resolved from the remote, as the cache is checked out detached
+	Resolve(ctx context.Context, dir string, ref string) (string, error)
+	Head(ctx context.Context, dir string) (string, error)
+	// DefaultBranch returns the branch the remote points HEAD at, i.e. main
+	DefaultBranch(ctx context.Context, dir string) (string, error)
+	Checkout(ctx context.Context, dir string, commit string) error
+}
+
+// ErrRefNotFound is returned when a ref can't be resolved in a registry
+var ErrRefNotFound = errors.New("ref not found")
+
+// GitError is returned by the git backends, it records which operation failed on which repository, and the output of git if it was run
+type GitError struct {
+	Op     string
+	Dir    string
+	Output string
+	Err    error
+}
+
+func (e *GitError) Error() string {
+	message := fmt.Sprintf("git %s failed", e.Op)
+	if e.Dir != "" {
+		message = fmt.Sprintf("%s in: %s", message, e.Dir)
+	}
+
+	message = fmt.Sprintf("%s: %s", message, e.Err)
+	if e.Output != "" {
+		message = fmt.Sprintf("%s\n%s", message, e.Output)
+	}
+
+	return message
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// NewGitBackend returns the pure go backend, falling back to the git binary if it is installed
+func NewGitBackend() GitBackend {
+	goGit := NewGoGit()
+	if _, err := exec.LookPath("git"); err != nil {
+		return goGit
+	}
+
+	return NewFallbackGit(goGit, NewExecGit())
+}
+
+// ExecGit shells out to the git binary
+type ExecGit struct{}
+
+func NewExecGit() *ExecGit {
+	return &ExecGit{}
+}
+
+// run runs a git command in dir, returning its trimmed output
+func (g *ExecGit) run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", &GitError{Op: args[0], Dir: dir, Output: strings.TrimSpace(string(output)), Err: err}
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (g *ExecGit) Clone(ctx context.Context, url string, dir string) error {
+	_, err := g.run(ctx, "", "clone", url, dir)
+	return err
+}
+
+func (g *ExecGit) Fetch(ctx context.Context, dir string) error {
+	_, err := g.run(ctx, dir, "fetch", "--tags", "--force", "origin")
+	return err
+}
+
+func (g *ExecGit) Resolve(ctx context.Context, dir string, ref string) (string, error) {
+	for _, candidate := range []string{"origin/" + ref, ref} {
+		commit, err := g.run(ctx, dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if err == nil {
+			return commit, nil
+		}
+	}
+
+	return "", &GitError{Op: "rev-parse", Dir: dir, Err: fmt.Errorf("%w: %s", ErrRefNotFound, ref)}
+}
+
+func (g *ExecGit) Head(ctx context.Context, dir string) (string, error) {
+	return g.run(ctx, dir, "rev-parse", "HEAD")
+}
+
+func (g *ExecGit) DefaultBranch(ctx context.Context, dir string) (string, error) {
+	branch, err := g.run(ctx, dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimPrefix(branch, "origin/"), nil
+}
+
+func (g *ExecGit) Checkout(ctx context.Context, dir string, commit string) error {
+	_, err := g.run(ctx, dir, "checkout", "--force", "--detach", commit)
+	return err
+}
+
+// GoGit is the pure go backend, it doesn't need git to be installed
+type GoGit struct{}
+
+var installFileTransport sync.Once
+
+func NewGoGit() *GoGit {
+	// The file transport of go-git runs git-upload-pack, local repositories are served in process instead
+	installFileTransport.Do(func() {
+		client.InstallProtocol("file", server.NewClient(server.DefaultLoader))
+	})
+
+	return &GoGit{}
+}
+
+const originHead = plumbing.ReferenceName("refs/remotes/origin/HEAD")
+
+func (g *GoGit) Clone(ctx context.Context, url string, dir string) error {
+	repo, err := git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+		URL:  url,
+		Tags: git.AllTags,
+	})
+	if err != nil {
+		return &GitError{Op: "clone", Dir: dir, Err: err}
+	}
+
+	// go-git doesn't record the default branch of the remote, which git clone does
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return &GitError{Op: "clone", Dir: dir, Err: err}
+	}
+
+	if head.Type() == plumbing.SymbolicReference {
+		remoteHead := plumbing.NewRemoteReferenceName("origin", head.Target().Short())
+		if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(originHead, remoteHead)); err != nil {
+			return &GitError{Op: "clone", Dir: dir, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func (g *GoGit) Fetch(ctx context.Context, dir string) error {
+	repo, err := g.open(dir, "fetch")
+	if err != nil {
+		return err
+	}
+
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs: []config.RefSpec{
+			"+refs/heads/*:refs/remotes/origin/*",
+			"+refs/tags/*:refs/tags/*",
+		},
+		Force: true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return &GitError{Op: "fetch", Dir: dir, Err: err}
+	}
+
+	return nil
+}
+
+func (g *GoGit) Resolve(_ context.Context, dir string, ref string) (string, error) {
+	repo, err := g.open(dir, "rev-parse")
+	if err != nil {
+		return "", err
+	}
+
+	for _, candidate := range []string{"origin/" + ref, ref} {
+		hash, err := repo.ResolveRevision(plumbing.Revision(candidate))
+		if err != nil {
+			continue
+		}
+
+		// Annotated tags resolve to the tag object, which is peeled to its commit
+		if tag, err := repo.TagObject(*hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				continue
+			}
+
+			return commit.Hash.String(), nil
+		}
+
+		return hash.String(), nil
+	}
+
+	return "", &GitError{Op: "rev-parse", Dir: dir, Err: fmt.Errorf("%w: %s", ErrRefNotFound, ref)}
+}
+
+func (g *GoGit) Head(_ context.Context, dir string) (string, error) {
+	repo, err := g.open(dir, "rev-parse")
+	if err != nil {
+		return "", err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", &GitError{Op: "rev-parse", Dir: dir, Err: err}
+	}
+
+	return head.Hash().String(), nil
+}
+
+func (g *GoGit) DefaultBranch(_ context.Context, dir string) (string, error) {
+	repo, err := g.open(dir, "symbolic-ref")
+	if err != nil {
+		return "", err
+	}
+
+	head, err := repo.Reference(originHead, false)
+	if err != nil {
+		return "", &GitError{Op: "symbolic-ref", Dir: dir, Err: err}
+	}
+
+	if head.Type() != plumbing.SymbolicReference {
+		return "", &GitError{Op: "symbolic-ref", Dir: dir, Err: fmt.Errorf("%s is not a symbolic ref", originHead)}
+	}
+
+	return strings.TrimPrefix(head.Target().Short(), "origin/"), nil
+}
+
+func (g *GoGit) Checkout(_ context.Context, dir string, commit string) error {
+	repo, err := g.open(dir, "checkout")
+	if err != nil {
+		return err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return &GitError{Op: "checkout", Dir: dir, Err: err}
+	}
+
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(commit), Force: true}); err != nil {
+		return &GitError{Op: "checkout", Dir: dir, Err: err}
+	}
+
+	return nil
+}
+
+func (g *GoGit) open(dir string, op string) (*git.Repository, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, &GitError{Op: op, Dir: dir, Err: err}
+	}
+
+	return repo, nil
+}
+
+// FallbackGit uses the primary backend, and retries with the fallback if it fails. A ref which isn't found is not retried, as the fallback wouldn't find it either
+type FallbackGit struct {
+	primary  GitBackend
+	fallback GitBackend
+}
+
+func NewFallbackGit(primary GitBackend, fallback GitBackend) *FallbackGit {
+	return &FallbackGit{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+func (g *FallbackGit) Clone(ctx context.Context, url string, dir string) error {
+	if err := g.primary.Clone(ctx, url, dir); err == nil || !g.shouldFallback(ctx, err) {
+		return err
+	}
+
+	// A failed clone may leave a partial repository behind, which the fallback can't clone into
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clean up failed clone: %s, %w", dir, err)
+	}
+
+	return g.fallback.Clone(ctx, url, dir)
+}
+
+func (g *FallbackGit) Fetch(ctx context.Context, dir string) error {
+	if err := g.primary.Fetch(ctx, dir); err == nil || !g.shouldFallback(ctx, err) {
+		return err
+	}
+
+	return g.fallback.Fetch(ctx, dir)
+}
+
+func (g *FallbackGit) Resolve(ctx context.Context, dir string, ref string) (string, error) {
+	commit, err := g.primary.Resolve(ctx, dir, ref)
+	if err == nil || !g.shouldFallback(ctx, err) {
+		return commit, err
+	}
+
+	return g.fallback.Resolve(ctx, dir, ref)
+}
+
+func (g *FallbackGit) Head(ctx context.Context, dir string) (string, error) {
+	commit, err := g.primary.Head(ctx, dir)
+	if err == nil || !g.shouldFallback(ctx, err) {
+		return commit, err
+	}
+
+	return g.fallback.Head(ctx, dir)
+}
+
+func (g *FallbackGit) DefaultBranch(ctx context.Context, dir string) (string, error) {
+	branch, err := g.primary.DefaultBranch(ctx, dir)
+	if err == nil || !g.shouldFallback(ctx, err) {
+		return branch, err
+	}
+
+	return g.fallback.DefaultBranch(ctx, dir)
+}
+
+func (g *FallbackGit) Checkout(ctx context.Context, dir string, commit string) error {
+	if err := g.primary.Checkout(ctx, dir, commit); err == nil || !g.shouldFallback(ctx, err) {
+		return err
+	}
+
+	return g.fallback.Checkout(ctx, dir, commit)
+}
+
+func (g *FallbackGit) shouldFallback(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !errors.Is(err, ErrRefNotFound)
+}
@@ -0,0 +1,218 @@
+package fetcher
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRemote is a bare repository, with a work repository pushing to it
+type testRemote struct {
+	t        *testing.T
+	bare     string
+	work     *git.Repository
+	workPath string
+}
+
+func newTestRemote(t *testing.T) *testRemote {
+	t.Helper()
+
+	bare := path.Join(t.TempDir(), "registry.git")
+	_, err := git.PlainInitWithOptions(bare, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+		Bare:        true,
+	})
+	require.NoError(t, err)
+
+	workPath := t.TempDir()
+	work, err := git.PlainInitWithOptions(workPath, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	require.NoError(t, err)
+
+	_, err = work.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{bare}})
+	require.NoError(t, err)
+
+	return &testRemote{t: t, bare: bare, work: work, workPath: workPath}
+}
+
+func (r *testRemote) commit(content string) string {
+	r.t.Helper()
+
+	require.NoError(r.t, os.WriteFile(path.Join(r.workPath, "scaffold.yaml"), []byte(content), 0o644))
+
+	worktree, err := r.work.Worktree()
+	require.NoError(r.t, err)
+
+	_, err = worktree.Add("scaffold.yaml")
+	require.NoError(r.t, err)
+
+	hash, err := worktree.Commit(content, &git.CommitOptions{
+		Author: &object.Signature{Name: "scaffold", Email: "scaffold@example.com", When: time.Now()},
+	})
+	require.NoError(r.t, err)
+
+	return hash.String()
+}
+
+func (r *testRemote) tag(name string, commit string) {
+	r.t.Helper()
+
+	_, err := r.work.CreateTag(name, plumbing.NewHash(commit), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "scaffold", Email: "scaffold@example.com", When: time.Now()},
+		Message: name,
+	})
+	require.NoError(r.t, err)
+}
+
+func (r *testRemote) push() {
+	r.t.Helper()
+
+	err := r.work.Push(&git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"},
+	})
+	require.NoError(r.t, err)
+}
+
+func gitBackends() map[string]GitBackend {
+	backends := map[string]GitBackend{
+		"go-git": NewGoGit(),
+	}
+
+	if _, err := exec.LookPath("git"); err == nil {
+		backends["exec"] = NewExecGit()
+	}
+
+	return backends
+}
+
+func TestGitBackends(t *testing.T) {
+	for name, backend := range gitBackends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+
+			remote := newTestRemote(t)
+			first := remote.commit("name: first\n")
+			remote.tag("v1", first)
+			remote.push()
+
+			dir := path.Join(t.TempDir(), "clone")
+			require.NoError(t, backend.Clone(ctx, remote.bare, dir))
+
+			branch, err := backend.DefaultBranch(ctx, dir)
+			require.NoError(t, err)
+			assert.Equal(t, "main", branch)
+
+			head, err := backend.Head(ctx, dir)
+			require.NoError(t, err)
+			assert.Equal(t, first, head)
+
+			second := remote.commit("name: second\n")
+			remote.push()
+
+			require.NoError(t, backend.Fetch(ctx, dir))
+			require.NoError(t, backend.Fetch(ctx, dir), "fetching when up to date")
+
+			commit, err := backend.Resolve(ctx, dir, "main")
+			require.NoError(t, err)
+			assert.Equal(t, second, commit, "branches resolve from the remote")
+
+			commit, err = backend.Resolve(ctx, dir, "v1")
+			require.NoError(t, err)
+			assert.Equal(t, first, commit, "annotated tags resolve to their commit")
+
+			commit, err = backend.Resolve(ctx, dir, first)
+			require.NoError(t, err)
+			assert.Equal(t, first, commit)
+
+			_, err = backend.Resolve(ctx, dir, "v2")
+			assert.ErrorIs(t, err, ErrRefNotFound)
+
+			require.NoError(t, backend.Checkout(ctx, dir, second))
+			head, err = backend.Head(ctx, dir)
+			require.NoError(t, err)
+			assert.Equal(t, second, head)
+
+			content, err := os.ReadFile(path.Join(dir, "scaffold.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, "name: second\n", string(content))
+		})
+	}
+}
+
+func TestGitSourceFileURL(t *testing.T) {
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	remote := newTestRemote(t)
+	commit := remote.commit("name: first\n")
+	remote.push()
+
+	cacheDir := t.TempDir()
+	registry := Registry{Name: "team", URL: "file://" + remote.bare}
+	source := NewFetcher(false).WithGitBackend(NewGoGit()).WithCacheDir(cacheDir).Source(registry, registry.URL)
+	require.IsType(t, &GitSource{}, source)
+
+	localRegistry, err := source.Fetch(ctx, ui)
+	require.NoError(t, err)
+	assert.Equal(t, commit, localRegistry.Commit)
+
+	require.NoError(t, os.RemoveAll(remote.bare))
+
+	source = NewFetcher(false).WithOffline(true).WithCacheDir(cacheDir).Source(registry, registry.URL)
+	require.IsType(t, &GitSource{}, source, "the source doesn't depend on the repository being on disk")
+
+	cached, err := source.Fetch(ctx, ui)
+	require.NoError(t, err)
+	assert.Equal(t, localRegistry, cached)
+}
+
+func TestGitError(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+
+	_, err := NewGoGit().Head(ctx, dir)
+
+	var gitErr *GitError
+	require.ErrorAs(t, err, &gitErr)
+	assert.Equal(t, "rev-parse", gitErr.Op)
+	assert.Equal(t, dir, gitErr.Dir)
+	assert.ErrorIs(t, err, git.ErrRepositoryNotExists)
+}
+
+func TestFallbackGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	ctx := t.Context()
+	remote := newTestRemote(t)
+	first := remote.commit("name: first\n")
+	remote.push()
+
+	dir := path.Join(t.TempDir(), "clone")
+	// The failed clone leaves a partial repository behind, which is cleaned up before falling back
+	require.NoError(t, os.MkdirAll(path.Join(dir, ".git"), 0o755))
+
+	backend := NewFallbackGit(NewGoGit(), NewExecGit())
+	require.NoError(t, backend.Clone(ctx, remote.bare, dir))
+
+	head, err := backend.Head(ctx, dir)
+	require.NoError(t, err)
+	assert.Equal(t, first, head)
+
+	_, err = backend.Resolve(ctx, dir, "missing")
+	assert.ErrorIs(t, err, ErrRefNotFound)
+}
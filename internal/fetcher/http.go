@@ -0,0 +1,288 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HTTPIndex is the index.json of an http registry, it points to a tarball or zip archive of the registry, and lists its templates
+type HTTPIndex struct {
+	// Archive is the url of the archive, relative to the index
+	Archive string `json:"archive"`
+	// Checksum is the sha256 of the archive
+	Checksum  string              `json:"checksum"`
+	Templates []HTTPIndexTemplate `json:"templates"`
+}
+
+type HTTPIndexTemplate struct {
+	// Name is the folder of the template in the registry
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Checksum is the sha256 of the template folder, see ChecksumDir. It is verified if set
+	Checksum string `json:"checksum,omitempty"`
+}
+
+const httpIndexFile = "index.json"
+
+func isHTTPIndex(registryURL string) bool {
+	return (strings.HasPrefix(registryURL, "https://") || strings.HasPrefix(registryURL, "http://")) &&
+		strings.HasSuffix(registryURL, "/"+httpIndexFile)
+}
+
+// HTTPSource is an http endpoint serving an index.json next to an archive of the registry, for environments which can't use git. The archive is verified against the checksums of the index, and cached like a git registry
+type HTTPSource struct {
+	fetcher  *Fetcher
+	registry Registry
+}
+
+func (s *HTTPSource) Name() string {
+	return s.registry.Name
+}
+
+// Fetch downloads the registry if it isn't cached, or updates it if the cache has expired. If an update fails the stale cache is used
+func (s *HTTPSource) Fetch(ctx context.Context, ui *slog.Logger) (LocalRegistry, error) {
+	f := s.fetcher
+
+	unlock, err := f.lockRegistry(ctx, ui, s.registry)
+	if err != nil {
+		return LocalRegistry{}, err
+	}
+	defer unlock()
+
+	if _, err := s.Cached(ctx); err != nil {
+		if f.offline {
+			return LocalRegistry{}, fmt.Errorf("registry: %s isn't cached, and scaffold is offline", s.registry)
+		}
+
+		ui.Info("downloading templates", "registry", s.registry.Name, "url", s.registry.URL)
+		if err := s.download(ctx, ui); err != nil {
+			return LocalRegistry{}, fmt.Errorf("failed to download registry: %s, %w", s.registry.URL, err)
+		}
+	} else if !f.offline {
+		lastUpdated := time.Unix(f.getCacheUpdate(ui, ctx, s.registry), 0)
+
+		if lastUpdated.Before(time.Now().Add(-f.ttl)) || f.ignoreCache {
+			ui.Info("update templates folder", "registry", s.registry.Name)
+			if err := s.download(ctx, ui); err != nil {
+				ui.Warn("failed to update registry, using the stale cache", "registry", s.registry.Name, "lastUpdated", lastUpdated, "error", err)
+			}
+		}
+	}
+
+	localRegistry, err := s.Cached(ctx)
+	if err != nil {
+		return LocalRegistry{}, err
+	}
+
+	ui.Info("using registry", "registry", s.registry.Name, "checksum", localRegistry.Commit)
+
+	return localRegistry, nil
+}
+
+// Cached returns the registry last downloaded, the checksum of its archive is used as the commit
+func (s *HTTPSource) Cached(_ context.Context) (LocalRegistry, error) {
+	clonePath := s.fetcher.clonePath(s.registry)
+
+	content, err := os.ReadFile(path.Join(clonePath, httpIndexFile))
+	if err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to find cached registry: %s, %w", s.registry.URL, err)
+	}
+
+	var index HTTPIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to read cached index: %s, %w", s.registry.URL, err)
+	}
+
+	return LocalRegistry{
+		Name:   s.registry.Name,
+		Path:   registryFolder(archiveRoot(path.Join(clonePath, "archive"))),
+		Commit: index.Checksum,
+	}, nil
+}
+
+// download fetches the index and the archive, and verifies them before replacing the cache
+func (s *HTTPSource) download(ctx context.Context, ui *slog.Logger) error {
+	indexURL, err := url.Parse(s.registry.URL)
+	if err != nil {
+		return fmt.Errorf("failed to parse registry url: %w", err)
+	}
+
+	indexContent, err := s.fetcher.httpGet(ctx, indexURL.String())
+	if err != nil {
+		return err
+	}
+
+	var index HTTPIndex
+	if err := json.Unmarshal(indexContent, &index); err != nil {
+		return fmt.Errorf("failed to parse index: %s, %w", indexURL, err)
+	}
+
+	if index.Archive == "" || index.Checksum == "" {
+		return fmt.Errorf("index: %s must have an archive and its checksum", indexURL)
+	}
+
+	archiveURL, err := indexURL.Parse(index.Archive)
+	if err != nil {
+		return fmt.Errorf("failed to parse archive url: %s, %w", index.Archive, err)
+	}
+
+	if !isArchive(archiveURL.Path) {
+		return fmt.Errorf("archive: %s is neither a tarball or a zip archive", archiveURL)
+	}
+
+	archive, err := s.fetcher.httpGet(ctx, archiveURL.String())
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256.Sum256(archive)
+	if actual := hex.EncodeToString(checksum[:]); !strings.EqualFold(actual, index.Checksum) {
+		return fmt.Errorf("checksum of archive: %s doesn't match the index, expected: %s, got: %s", archiveURL, index.Checksum, actual)
+	}
+
+	clonePath := s.fetcher.clonePath(s.registry)
+	if err := os.MkdirAll(path.Dir(clonePath), readWriteExec); err != nil {
+		return fmt.Errorf("failed to create scaffold folder: %w", err)
+	}
+
+	// The download is prepared next to the cache, so a failed download leaves the cache untouched
+	downloadPath := clonePath + ".download"
+	defer os.RemoveAll(downloadPath)
+
+	if err := os.RemoveAll(downloadPath); err != nil {
+		return fmt.Errorf("failed to clean download folder: %s, %w", downloadPath, err)
+	}
+
+	if err := os.MkdirAll(downloadPath, readWriteExec); err != nil {
+		return fmt.Errorf("failed to create download folder: %s, %w", downloadPath, err)
+	}
+
+	archivePath := path.Join(downloadPath, path.Base(archiveURL.Path))
+	if err := os.WriteFile(archivePath, archive, 0o644); err != nil {
+		return fmt.Errorf("failed to write archive: %s, %w", archivePath, err)
+	}
+
+	extractPath := path.Join(downloadPath, "archive")
+	if err := extractArchive(archivePath, extractPath); err != nil {
+		return fmt.Errorf("failed to extract archive: %s, %w", archiveURL, err)
+	}
+
+	if err := os.Remove(archivePath); err != nil {
+		return fmt.Errorf("failed to remove archive: %s, %w", archivePath, err)
+	}
+
+	registryPath := registryFolder(archiveRoot(extractPath))
+	for _, template := range index.Templates {
+		ui.Debug("found template", "registry", s.registry.Name, "template", template.Name, "version", template.Version)
+
+		if template.Checksum == "" {
+			continue
+		}
+
+		actual, err := ChecksumDir(path.Join(registryPath, template.Name))
+		if err != nil {
+			return fmt.Errorf("failed to checksum template: %s, %w", template.Name, err)
+		}
+
+		if !strings.EqualFold(actual, template.Checksum) {
+			return fmt.Errorf("checksum of template: %s doesn't match the index, expected: %s, got: %s", template.Name, template.Checksum, actual)
+		}
+	}
+
+	if err := os.WriteFile(path.Join(downloadPath, httpIndexFile), indexContent, 0o644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	if err := os.RemoveAll(clonePath); err != nil {
+		return fmt.Errorf("failed to remove the previous download: %s, %w", clonePath, err)
+	}
+
+	if err := os.Rename(downloadPath, clonePath); err != nil {
+		return fmt.Errorf("failed to move download into the cache: %s, %w", clonePath, err)
+	}
+
+	return s.fetcher.createCacheUpdate(ctx, s.registry)
+}
+
+func (f *Fetcher) httpGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %s, %w", url, err)
+	}
+
+	client := &http.Client{Timeout: f.httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get: %s, %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get: %s, status: %s", url, resp.Status)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read: %s, %w", url, err)
+	}
+
+	return content, nil
+}
+
+// ChecksumDir is the sha256 of a folder, which is the same as `find . -type f | LC_ALL=C sort | xargs sha256sum | sha256sum` run in the folder
+func ChecksumDir(dir string) (string, error) {
+	files := make([]string, 0)
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.Type().IsRegular() {
+			relPath, err := filepath.Rel(dir, filePath)
+			if err != nil {
+				return err
+			}
+
+			files = append(files, "./"+filepath.ToSlash(relPath))
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to find: %s, %w", dir, err)
+		}
+
+		return "", fmt.Errorf("failed to walk: %s, %w", dir, err)
+	}
+
+	sort.Strings(files)
+
+	sum := sha256.New()
+	for _, file := range files {
+		content, err := os.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return "", fmt.Errorf("failed to read: %s, %w", file, err)
+		}
+
+		fileSum := sha256.Sum256(content)
+		fmt.Fprintf(sum, "%s  %s\n", hex.EncodeToString(fileSum[:]), file)
+	}
+
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
@@ -0,0 +1,228 @@
+package fetcher
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tarWriter.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+
+	return buffer.Bytes()
+}
+
+// testHTTPRegistry serves an index.json and registry.tar.gz, the index can be changed by the test
+type testHTTPRegistry struct {
+	server  *httptest.Server
+	archive []byte
+	index   HTTPIndex
+}
+
+func newTestHTTPRegistry(t *testing.T) *testHTTPRegistry {
+	t.Helper()
+
+	registry := &testHTTPRegistry{
+		archive: testArchive(t, map[string]string{
+			"scaffold-registry/registry/service/scaffold.yaml":     "name: service\n",
+			"scaffold-registry/registry/service/files/main.go":     "package main\n",
+			"scaffold-registry/registry/library/scaffold.yaml":     "name: library\n",
+			"scaffold-registry/registry/library/files/lib.go.tmpl": "package lib\n",
+		}),
+	}
+
+	checksum := sha256.Sum256(registry.archive)
+	registry.index = HTTPIndex{
+		Archive:  "registry.tar.gz",
+		Checksum: hex.EncodeToString(checksum[:]),
+		Templates: []HTTPIndexTemplate{
+			{Name: "service", Version: "1.0.0"},
+			{Name: "library", Version: "0.1.0"},
+		},
+	}
+
+	registry.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/scaffold/index.json":
+			require.NoError(t, json.NewEncoder(w).Encode(registry.index))
+		case "/scaffold/registry.tar.gz":
+			_, _ = w.Write(registry.archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(registry.server.Close)
+
+	return registry
+}
+
+func (r *testHTTPRegistry) url() string {
+	return r.server.URL + "/scaffold/index.json"
+}
+
+func TestHTTPSource(t *testing.T) {
+	cacheDir := t.TempDir()
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	registry := newTestHTTPRegistry(t)
+	serviceChecksum := ""
+
+	fetcher := NewFetcher(false).WithCacheDir(cacheDir)
+	source := fetcher.Source(Registry{Name: "team", URL: registry.url()}, registry.url())
+	require.IsType(t, &HTTPSource{}, source)
+
+	_, err := source.Cached(ctx)
+	assert.Error(t, err, "nothing is cached before the first fetch")
+
+	localRegistry, err := source.Fetch(ctx, ui)
+	require.NoError(t, err)
+	assert.Equal(t, "team", localRegistry.Name)
+	assert.Equal(t, registry.index.Checksum, localRegistry.Commit)
+
+	content, err := os.ReadFile(path.Join(localRegistry.Path, "service", "scaffold.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: service\n", string(content))
+
+	serviceChecksum, err = ChecksumDir(path.Join(localRegistry.Path, "service"))
+	require.NoError(t, err)
+
+	cached, err := source.Cached(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, localRegistry, cached)
+
+	t.Run("verifies the checksum of the archive", func(t *testing.T) {
+		registry.index.Checksum = "invalid"
+		t.Cleanup(func() { registry.index.Checksum = localRegistry.Commit })
+
+		err := source.(*HTTPSource).download(ctx, ui)
+		assert.ErrorContains(t, err, "checksum of archive")
+	})
+
+	t.Run("verifies the checksum of the templates", func(t *testing.T) {
+		registry.index.Templates[0].Checksum = serviceChecksum
+		require.NoError(t, source.(*HTTPSource).download(ctx, ui))
+
+		registry.index.Templates[1].Checksum = serviceChecksum
+		t.Cleanup(func() { registry.index.Templates[1].Checksum = "" })
+
+		err := source.(*HTTPSource).download(ctx, ui)
+		assert.ErrorContains(t, err, "checksum of template: library")
+
+		cached, err := source.Cached(ctx)
+		require.NoError(t, err, "a failed download leaves the cache untouched")
+		assert.Equal(t, localRegistry, cached)
+	})
+
+	t.Run("an unresponsive server times out", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		_, err := NewFetcher(false).WithCacheDir(t.TempDir()).WithHTTPTimeout(50*time.Millisecond).Source(Registry{Name: "slow", URL: server.URL + "/index.json"}, server.URL+"/index.json").Fetch(ctx, ui)
+		assert.ErrorContains(t, err, "Client.Timeout exceeded")
+	})
+
+	t.Run("uses the stale cache if the update fails", func(t *testing.T) {
+		registry.server.Close()
+
+		staleRegistry, err := NewFetcher(true).WithCacheDir(cacheDir).Source(Registry{Name: "team", URL: registry.url()}, registry.url()).Fetch(ctx, ui)
+		require.NoError(t, err)
+		assert.Equal(t, localRegistry, staleRegistry)
+	})
+
+	t.Run("offline names the url of a registry without a name", func(t *testing.T) {
+		_, err := NewFetcher(true).WithCacheDir(t.TempDir()).WithOffline(true).Source(Registry{URL: registry.url()}, registry.url()).Fetch(ctx, ui)
+		assert.ErrorContains(t, err, fmt.Sprintf("registry: %s isn't cached, and scaffold is offline", registry.url()))
+	})
+}
+
+func TestSource(t *testing.T) {
+	fetcher := NewFetcher(false).WithCacheDir(t.TempDir())
+
+	for location, expected := range map[string]RegistrySource{
+		"https://example.com/scaffold/index.json": &HTTPSource{},
+		"https://github.com/kjuulh/scaffold":      &GitSource{},
+		"git@github.com:kjuulh/scaffold.git":      &GitSource{},
+		"file:///srv/registry.git":                &GitSource{},
+		"/srv/missing/registry.git":               &GitSource{},
+		"./registry.tar.gz":                       &ArchiveSource{},
+		"file:///tmp/registry.zip":                &ArchiveSource{},
+		"./registry":                              &LocalSource{},
+	} {
+		url, ref := ParseRegistryURL(location)
+		assert.IsType(t, expected, fetcher.Source(Registry{URL: url, Ref: ref}, location), location)
+	}
+}
+
+func TestArchiveSource(t *testing.T) {
+	ctx := t.Context()
+	archivePath := path.Join(t.TempDir(), "registry.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, testArchive(t, map[string]string{
+		"registry/service/scaffold.yaml": "name: service\n",
+	}), 0o644))
+
+	cacheDir := t.TempDir()
+	source := NewFetcher(false).WithCacheDir(cacheDir).Source(Registry{URL: archivePath}, archivePath)
+
+	localRegistry, err := source.Cached(ctx)
+	require.NoError(t, err)
+	assert.FileExists(t, path.Join(localRegistry.Path, "service", "scaffold.yaml"))
+
+	// A file added to the extracted archive shows whether it was extracted again
+	marker := path.Join(localRegistry.Path, "extracted")
+	require.NoError(t, os.WriteFile(marker, nil, 0o644))
+
+	_, err = source.Cached(ctx)
+	require.NoError(t, err)
+	assert.FileExists(t, marker, "an unchanged archive isn't extracted again")
+
+	require.NoError(t, os.WriteFile(archivePath, testArchive(t, map[string]string{
+		"registry/service/scaffold.yaml": "name: service\n",
+		"registry/library/scaffold.yaml": "name: library\n",
+	}), 0o644))
+
+	localRegistry, err = source.Cached(ctx)
+	require.NoError(t, err)
+	assert.NoFileExists(t, marker, "a changed archive is extracted again")
+	assert.FileExists(t, path.Join(localRegistry.Path, "library", "scaffold.yaml"))
+
+	require.NoError(t, os.WriteFile(marker, nil, 0o644))
+	_, err = NewFetcher(true).WithCacheDir(cacheDir).Source(Registry{URL: archivePath}, archivePath).Fetch(ctx, nil)
+	require.NoError(t, err)
+	assert.NoFileExists(t, marker, "forcing a cache update extracts the archive again")
+}
@@ -0,0 +1,92 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+const lockPollInterval = 100 * time.Millisecond
+
+// lockRegistry takes an advisory lock on the cache of a registry, so parallel scaffold runs don't update it at the same time. It waits until the lock is released by the other run, or the context is cancelled
+func (f *Fetcher) lockRegistry(ctx context.Context, ui *slog.Logger, registry Registry) (func(), error) {
+	return lockFile(ctx, ui, registry.Name, f.clonePath(registry)+".lock")
+}
+
+// unsupportedLockWarning makes sure the missing lock is only warned about once per run
+var unsupportedLockWarning sync.Once
+
+func lockFile(ctx context.Context, ui *slog.Logger, name string, lockPath string) (func(), error) {
+	if err := os.MkdirAll(path.Dir(lockPath), readWriteExec); err != nil {
+		return nil, fmt.Errorf("failed to create lock folder: %s, %w", lockPath, err)
+	}
+
+	if !lockSupported && ui != nil {
+		unsupportedLockWarning.Do(func() {
+			ui.Warn("locking the cache isn't supported on this platform, parallel scaffold runs may update a registry at the same time", "registry", name)
+		})
+	}
+
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock: %s, %w", lockPath, err)
+	}
+
+	waiting := false
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to take lock: %s, %w", lockPath, err)
+		}
+
+		if locked {
+			break
+		}
+
+		if !waiting && ui != nil {
+			ui.Info("waiting for another scaffold to finish updating the registry", "registry", name)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, fmt.Errorf("failed to take lock: %s, %w", lockPath, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	return func() {
+		_ = unlock(file)
+		file.Close()
+	}, nil
+}
+
+// writeFileAtomic writes to a temporary file next to the file, and renames it into place, so readers never see a partially written file
+func writeFileAtomic(filePath string, content []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(path.Dir(filePath), path.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(file.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), filePath)
+}
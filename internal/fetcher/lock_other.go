@@ -0,0 +1,16 @@
+//go:build !unix && !windows
+
+package fetcher
+
+import "os"
+
+// lockSupported is false on platforms with neither flock nor LockFileEx, where parallel runs aren't guarded
+const lockSupported = false
+
+func tryLock(_ *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlock(_ *os.File) error {
+	return nil
+}
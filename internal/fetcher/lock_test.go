@@ -0,0 +1,73 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestLockRegistry(t *testing.T) {
+	ctx := t.Context()
+	fetcher := NewFetcher(false).WithCacheDir(t.TempDir())
+	registry := Registry{Name: "team", URL: "https://github.com/team/scaffold-registry.git"}
+
+	unlock, err := fetcher.lockRegistry(ctx, nil, registry)
+	require.NoError(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*lockPollInterval)
+	defer cancel()
+
+	_, err = fetcher.lockRegistry(timeoutCtx, nil, registry)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "the lock is held by the first run")
+
+	unlock()
+
+	unlock, err = fetcher.lockRegistry(ctx, nil, registry)
+	require.NoError(t, err, "the lock is released")
+	unlock()
+}
+
+func TestCloneRepositoryConcurrently(t *testing.T) {
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	remote := newTestRemote(t)
+	commit := remote.commit("name: first\n")
+	remote.push()
+
+	registry := Registry{Name: "team", URL: remote.bare}
+	// Forcing updates makes every run fetch, and write the cache metadata
+	fetcher := NewFetcher(true).WithGitBackend(NewGoGit()).WithCacheDir(t.TempDir())
+
+	egrp, ctx := errgroup.WithContext(ctx)
+	for range 8 {
+		egrp.Go(func() error {
+			localRegistry, err := fetcher.CloneRepository(ctx, registry, ui)
+			if err != nil {
+				return err
+			}
+
+			assert.Equal(t, commit, localRegistry.Commit)
+
+			return nil
+		})
+	}
+	require.NoError(t, egrp.Wait())
+
+	lastUpdated := fetcher.getCacheUpdate(ui, ctx, registry)
+	assert.WithinDuration(t, time.Now(), time.Unix(lastUpdated, 0), time.Minute)
+
+	entries, err := os.ReadDir(path.Dir(fetcher.cachePath(registry)))
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".tmp", "no temporary cache metadata is left behind")
+	}
+}
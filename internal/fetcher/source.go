@@ -0,0 +1,177 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// RegistrySource is somewhere the templates of a registry can be fetched from, such as a git repository, a local directory, an archive or an http index
+type RegistrySource interface {
+	// Name is what the templates of the registry are namespaced with, empty if they aren't namespaced
+	Name() string
+	// Fetch makes the registry available on disk, fetching or updating it as needed
+	Fetch(ctx context.Context, ui *slog.Logger) (LocalRegistry, error)
+	// Cached returns the registry if it is already on disk, without fetching anything
+	Cached(ctx context.Context) (LocalRegistry, error)
+}
+
+// Sources returns the sources of the configured registries. If a registryPath is given, that is used instead
+func (f *Fetcher) Sources(registryPath string) ([]RegistrySource, error) {
+	if registryPath != "" {
+		url, ref := ParseRegistryURL(registryPath)
+
+		return []RegistrySource{f.Source(Registry{Name: "", URL: url, Ref: ref}, registryPath)}, nil
+	}
+
+	registries, err := f.Registries()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(registries) == 0 {
+		return nil, errors.New("failed to find SCAFFOLD_REGISTRY set the environment variable to a fork of https://github.com/kjuulh/scaffold-registry")
+	}
+
+	sources := make([]RegistrySource, 0, len(registries))
+	for _, registry := range registries {
+		sources = append(sources, f.Source(registry, registry.URL))
+	}
+
+	return sources, nil
+}
+
+// Source picks the source of a registry from where it points. An url to an index.json is an http registry, any other url, or a path ending in .git such as a bare repository on disk, is a git registry. A tarball or zip archive (optionally as a file:// url) is extracted into the cache, and a local directory is used in place
+func (f *Fetcher) Source(registry Registry, location string) RegistrySource {
+	switch {
+	case isHTTPIndex(registry.URL):
+		return &HTTPSource{fetcher: f, registry: registry}
+	case isGitURL(registry.URL):
+		return &GitSource{fetcher: f, registry: registry}
+	}
+
+	localPath := strings.TrimPrefix(location, "file://")
+	if isArchive(localPath) {
+		return &ArchiveSource{fetcher: f, name: registry.Name, path: localPath}
+	}
+
+	return &LocalSource{name: registry.Name, path: localPath}
+}
+
+// GitSource is a git repository, which is cloned into the cache and checked out at its ref
+type GitSource struct {
+	fetcher  *Fetcher
+	registry Registry
+}
+
+func (s *GitSource) Name() string {
+	return s.registry.Name
+}
+
+func (s *GitSource) Fetch(ctx context.Context, ui *slog.Logger) (LocalRegistry, error) {
+	if err := os.MkdirAll(path.Dir(s.fetcher.clonePath(s.registry)), readWriteExec); err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to create scaffold folder: %w", err)
+	}
+
+	return s.fetcher.CloneRepository(ctx, s.registry, ui)
+}
+
+func (s *GitSource) Cached(ctx context.Context) (LocalRegistry, error) {
+	return s.fetcher.local(ctx, s.registry)
+}
+
+// LocalSource is a directory on disk, which is used in place
+type LocalSource struct {
+	name string
+	path string
+}
+
+func (s *LocalSource) Name() string {
+	return s.name
+}
+
+func (s *LocalSource) Fetch(ctx context.Context, _ *slog.Logger) (LocalRegistry, error) {
+	return s.Cached(ctx)
+}
+
+func (s *LocalSource) Cached(_ context.Context) (LocalRegistry, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to find registry: %s, %w", s.path, err)
+	}
+
+	if !info.IsDir() {
+		return LocalRegistry{}, fmt.Errorf("registry: %s is neither a directory, a git url, an index.json url or a tarball or zip archive", s.path)
+	}
+
+	return LocalRegistry{Name: s.name, Path: registryFolder(s.path)}, nil
+}
+
+// ArchiveSource is a tarball or zip archive on disk, which is extracted into the cache
+type ArchiveSource struct {
+	fetcher *Fetcher
+	name    string
+	path    string
+}
+
+func (s *ArchiveSource) Name() string {
+	return s.name
+}
+
+// Fetch extracts the archive if it changed since it was last extracted, or always with --force-cache-update
+func (s *ArchiveSource) Fetch(ctx context.Context, _ *slog.Logger) (LocalRegistry, error) {
+	return s.extract(ctx, s.fetcher.ignoreCache)
+}
+
+// Cached reuses the extracted archive, unless the archive changed since it was extracted, as it is called on every run to find the templates
+func (s *ArchiveSource) Cached(ctx context.Context) (LocalRegistry, error) {
+	return s.extract(ctx, false)
+}
+
+func (s *ArchiveSource) extract(ctx context.Context, force bool) (LocalRegistry, error) {
+	absolutePath, err := filepath.Abs(s.path)
+	if err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to find registry: %s, %w", s.path, err)
+	}
+
+	archiveInfo, err := os.Stat(absolutePath)
+	if err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to find registry: %s, %w", s.path, err)
+	}
+
+	extractPath := s.fetcher.unnamedPath(absolutePath)
+	unlock, err := lockFile(ctx, nil, s.name, extractPath+".lock")
+	if err != nil {
+		return LocalRegistry{}, err
+	}
+	defer unlock()
+
+	// The size and modification time of the archive tell whether it changed, without reading it
+	stampPath := extractPath + ".archive"
+	stamp := fmt.Sprintf("%d %d", archiveInfo.Size(), archiveInfo.ModTime().UnixNano())
+	if existingStamp, err := os.ReadFile(stampPath); !force && err == nil && string(existingStamp) == stamp {
+		if _, err := os.Stat(extractPath); err == nil {
+			return LocalRegistry{Name: s.name, Path: registryFolder(archiveRoot(extractPath))}, nil
+		}
+	}
+
+	// The stamp is removed first, so an extraction which fails halfway is never reused
+	if err := os.Remove(stampPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return LocalRegistry{}, fmt.Errorf("failed to clean archive stamp: %s, %w", stampPath, err)
+	}
+
+	if err := extractArchive(absolutePath, extractPath); err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to extract registry: %s, %w", s.path, err)
+	}
+
+	if err := writeFileAtomic(stampPath, []byte(stamp), 0o644); err != nil {
+		return LocalRegistry{}, fmt.Errorf("failed to write archive stamp: %s, %w", stampPath, err)
+	}
+
+	return LocalRegistry{Name: s.name, Path: registryFolder(archiveRoot(extractPath))}, nil
+}
@@ -0,0 +1,76 @@
+package home
+
+import (
+	"os"
+	"path"
+
+	"github.com/kjuulh/scaffold/internal/config"
+)
+
+// Dirs are where scaffold keeps its files, Config holds the configuration of the user, and Cache holds the registries, which can be removed at any time
+type Dirs struct {
+	Config string
+	Cache  string
+	// Legacy is ~/.scaffold from earlier versions when it is still used for the config or the cache, see XDG for where to move it
+	Legacy string
+}
+
+// Resolve finds the scaffold directories. A home given by --home or SCAFFOLD_HOME holds both the config and the cache. Otherwise XDG_CONFIG_HOME and XDG_CACHE_HOME are used when they're set, defaulting to ~/.config/scaffold and ~/.cache/scaffold.
+// ~/.scaffold from earlier versions is still used for what isn't set explicitly, though a config.yaml in ~/.config/scaffold takes precedence over it
+func Resolve(home string) Dirs {
+	if home == "" {
+		home = os.Getenv("SCAFFOLD_HOME")
+	}
+
+	if home != "" {
+		return Dirs{Config: home, Cache: home}
+	}
+
+	userHome, _ := os.UserHomeDir()
+	dirs, configSet, cacheSet := xdgDirs(userHome)
+
+	legacyHome := path.Join(userHome, ".scaffold")
+	if info, err := os.Stat(legacyHome); err != nil || !info.IsDir() {
+		return dirs
+	}
+
+	if _, err := os.Stat(path.Join(dirs.Config, config.ConfigFile)); !configSet && err != nil {
+		dirs.Config = legacyHome
+		dirs.Legacy = legacyHome
+	}
+
+	if !cacheSet {
+		dirs.Cache = legacyHome
+		dirs.Legacy = legacyHome
+	}
+
+	return dirs
+}
+
+// XDG returns the XDG directories of scaffold, regardless of ~/.scaffold, which is where its config.yaml and cache are moved to
+func XDG() Dirs {
+	userHome, _ := os.UserHomeDir()
+	dirs, _, _ := xdgDirs(userHome)
+
+	return dirs
+}
+
+func xdgDirs(userHome string) (Dirs, bool, bool) {
+	configDir, configSet := xdgDir("XDG_CONFIG_HOME", path.Join(userHome, ".config"))
+	cacheDir, cacheSet := xdgDir("XDG_CACHE_HOME", path.Join(userHome, ".cache"))
+
+	return Dirs{
+		Config: path.Join(configDir, "scaffold"),
+		Cache:  path.Join(cacheDir, "scaffold"),
+	}, configSet, cacheSet
+}
+
+// xdgDir returns the directory of the XDG variable and whether it is set, relative paths are ignored as required by the spec
+func xdgDir(variable string, fallback string) (string, bool) {
+	dir := os.Getenv(variable)
+	if dir == "" || !path.IsAbs(dir) {
+		return fallback, false
+	}
+
+	return dir, true
+}
@@ -0,0 +1,67 @@
+package home
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	userHome := t.TempDir()
+	t.Setenv("HOME", userHome)
+	t.Setenv("SCAFFOLD_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+
+	assert.Equal(t, Dirs{
+		Config: path.Join(userHome, ".config", "scaffold"),
+		Cache:  path.Join(userHome, ".cache", "scaffold"),
+	}, Resolve(""))
+
+	t.Setenv("XDG_CONFIG_HOME", "/etc/xdg")
+	t.Setenv("XDG_CACHE_HOME", "relative/cache")
+	assert.Equal(t, Dirs{
+		Config: "/etc/xdg/scaffold",
+		Cache:  path.Join(userHome, ".cache", "scaffold"),
+	}, Resolve(""), "relative xdg dirs are ignored")
+
+	require.NoError(t, os.Mkdir(path.Join(userHome, ".scaffold"), 0o755))
+	assert.Equal(t, Dirs{
+		Config: "/etc/xdg/scaffold",
+		Cache:  path.Join(userHome, ".scaffold"),
+		Legacy: path.Join(userHome, ".scaffold"),
+	}, Resolve(""), "an explicit xdg dir takes precedence over the home of earlier versions")
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	assert.Equal(t, Dirs{
+		Config: path.Join(userHome, ".scaffold"),
+		Cache:  path.Join(userHome, ".scaffold"),
+		Legacy: path.Join(userHome, ".scaffold"),
+	}, Resolve(""), "the home of earlier versions is kept")
+	assert.Equal(t, Dirs{
+		Config: path.Join(userHome, ".config", "scaffold"),
+		Cache:  path.Join(userHome, ".cache", "scaffold"),
+	}, XDG(), "the xdg dirs are where the home of earlier versions is moved to")
+
+	t.Setenv("XDG_CACHE_HOME", "/var/cache")
+	assert.Equal(t, Dirs{
+		Config: path.Join(userHome, ".scaffold"),
+		Cache:  "/var/cache/scaffold",
+		Legacy: path.Join(userHome, ".scaffold"),
+	}, Resolve(""))
+
+	require.NoError(t, os.MkdirAll(path.Join(userHome, ".config", "scaffold"), 0o755))
+	require.NoError(t, os.WriteFile(path.Join(userHome, ".config", "scaffold", "config.yaml"), []byte("registries: []\n"), 0o644))
+	assert.Equal(t, Dirs{
+		Config: path.Join(userHome, ".config", "scaffold"),
+		Cache:  "/var/cache/scaffold",
+	}, Resolve(""), "a config.yaml in the xdg config dir takes precedence over the home of earlier versions")
+
+	t.Setenv("SCAFFOLD_HOME", "/opt/scaffold")
+	assert.Equal(t, Dirs{Config: "/opt/scaffold", Cache: "/opt/scaffold"}, Resolve(""))
+
+	assert.Equal(t, Dirs{Config: "/tmp/scaffold", Cache: "/tmp/scaffold"}, Resolve("/tmp/scaffold"), "--home takes precedence")
+}
@@ -0,0 +1,54 @@
+package templates
+
+import (
+	"bytes"
+	"regexp"
+	"slices"
+)
+
+// fenceMarker matches a generated block marker such as `// scaffold:begin routes-users`, the comment syntax is up to the template. The id ends at whitespace or the end of the line, so routes-users doesn't match routes-users2
+var fenceMarker = regexp.MustCompile(`scaffold:begin\s+(\S+)`)
+
+// alreadyApplied tells whether content has already been added to existing. A block fenced with scaffold:begin markers is applied if markers with the same ids are present, even if the block has since been edited, otherwise the lines of the content have to be present as whole lines, ignoring their indentation
+func alreadyApplied(existing, content []byte) bool {
+	markers := fenceMarker.FindAllSubmatch(content, -1)
+	if len(markers) > 0 {
+		existingIDs := make(map[string]bool)
+		for _, marker := range fenceMarker.FindAllSubmatch(existing, -1) {
+			existingIDs[string(marker[1])] = true
+		}
+
+		for _, marker := range markers {
+			if !existingIDs[string(marker[1])] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	block := bytes.TrimSpace(content)
+	if len(block) == 0 {
+		return false
+	}
+
+	blockLines := trimmedLines(block)
+	existingLines := trimmedLines(existing)
+	for i := 0; i+len(blockLines) <= len(existingLines); i++ {
+		if slices.EqualFunc(existingLines[i:i+len(blockLines)], blockLines, bytes.Equal) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trimmedLines splits content into lines without their surrounding whitespace
+func trimmedLines(content []byte) [][]byte {
+	lines := bytes.Split(content, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimSpace(line)
+	}
+
+	return lines
+}
@@ -0,0 +1,69 @@
+package templates
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// Diagnostic is a problem with a template in a registry. The template is skipped, while the rest of the registry can still be used
+type Diagnostic struct {
+	// Registry is the name of the registry of the template, empty if it isn't namespaced
+	Registry string
+	// Path is the folder of the template, relative to the registry
+	Path string
+	Err  error
+	// Warning is a problem which doesn't stop the template from being used, i.e. scaffold.yaml not matching the schema
+	Warning bool
+}
+
+// Template is the template the diagnostic is about, by its folder as its name may be what couldn't be read
+func (d Diagnostic) Template() string {
+	if d.Registry == "" {
+		return d.Path
+	}
+
+	return path.Join(d.Registry, d.Path)
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("template: %s, %s", d.Template(), d.Err.Error())
+}
+
+func (d Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// SkippedTemplates counts the templates which couldn't be loaded, leaving out the warnings about templates which are still used
+func SkippedTemplates(diagnostics []Diagnostic) int {
+	skipped := 0
+	for _, diagnostic := range diagnostics {
+		if !diagnostic.Warning {
+			skipped++
+		}
+	}
+
+	return skipped
+}
+
+// Diagnostics returns the problems found in the templates indexed so far, sorted by template
+func (t *TemplateIndexer) Diagnostics() []Diagnostic {
+	t.diagnosticsLock.Lock()
+	defer t.diagnosticsLock.Unlock()
+
+	diagnostics := make([]Diagnostic, len(t.diagnostics))
+	copy(diagnostics, t.diagnostics)
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Template() < diagnostics[j].Template()
+	})
+
+	return diagnostics
+}
+
+func (t *TemplateIndexer) addDiagnostics(diagnostics []Diagnostic) {
+	t.diagnosticsLock.Lock()
+	defer t.diagnosticsLock.Unlock()
+
+	t.diagnostics = append(t.diagnostics, diagnostics...)
+}
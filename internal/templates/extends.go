@@ -0,0 +1,86 @@
+package templates
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// resolveBase resolves the chain of templates the template extends, and merges what it inherits into its File. The stack is the full names of the templates extending it, to detect cycles
+func resolveBase(templates []Template, template Template, stack []string) (Template, error) {
+	if template.File.Extends == "" {
+		return template, nil
+	}
+
+	stack = append(slices.Clone(stack), template.FullName())
+
+	base, err := findTemplate(templates, &template, template.File.Extends)
+	if err != nil {
+		return Template{}, fmt.Errorf("extends: %w", err)
+	}
+
+	if slices.Contains(stack, base.FullName()) {
+		return Template{}, fmt.Errorf("template extends itself: %s -> %s", strings.Join(stack, " -> "), base.FullName())
+	}
+
+	resolvedBase, err := resolveBase(templates, *base, stack)
+	if err != nil {
+		return Template{}, err
+	}
+
+	template.File = extendTemplateFile(resolvedBase.File, template.File)
+	template.Base = &resolvedBase
+
+	return template, nil
+}
+
+// extendTemplateFile merges the base into the template extending it. Inputs and files are merged one by one, with the ones of the template taking precedence, the rest is inherited if the template leaves it empty.
+// The name, version and deprecation belong to the template itself, and aren't inherited
+func extendTemplateFile(base TemplateFile, template TemplateFile) TemplateFile {
+	extended := template
+
+	if extended.Description == "" {
+		extended.Description = base.Description
+	}
+
+	if len(extended.Tags) == 0 {
+		extended.Tags = base.Tags
+	}
+
+	if len(extended.Owners) == 0 {
+		extended.Owners = base.Owners
+	}
+
+	if extended.MinScaffoldVersion == "" {
+		extended.MinScaffoldVersion = base.MinScaffoldVersion
+	}
+
+	if extended.Default.Path == "" {
+		extended.Default.Path = base.Default.Path
+	}
+
+	if base.Input != nil || template.Input != nil {
+		extended.Input = make(TemplateInputs, len(base.Input)+len(template.Input))
+		maps.Copy(extended.Input, base.Input)
+		maps.Copy(extended.Input, template.Input)
+	}
+
+	if base.Files != nil || template.Files != nil {
+		extended.Files = make(map[string]TemplateFileConfig, len(base.Files)+len(template.Files))
+		maps.Copy(extended.Files, base.Files)
+		maps.Copy(extended.Files, template.Files)
+	}
+
+	if extended.Hooks.Pre == nil {
+		extended.Hooks.Pre = base.Hooks.Pre
+	}
+
+	if extended.Hooks.Post == nil {
+		extended.Hooks.Post = base.Hooks.Post
+	}
+
+	extended.Uses = slices.Concat(base.Uses, template.Uses)
+
+	return extended
+}
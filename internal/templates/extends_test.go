@@ -0,0 +1,152 @@
+package templates
+
+import (
+	"bytes"
+	"log/slog"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendTemplateFile(t *testing.T) {
+	base := TemplateFile{
+		Name:        "service",
+		Description: "a service",
+		Tags:        []string{"go"},
+		Version:     "1.0.0",
+		Default:     TemplateDefault{Path: "services"},
+		Input: TemplateInputs{
+			"name": {Type: "string"},
+			"port": {Type: "string", Default: "8080"},
+		},
+		Files: map[string]TemplateFileConfig{
+			"main.go":   {Rename: "cmd/main.go"},
+			"README.md": {Rename: "docs/README.md"},
+		},
+		Uses: []TemplateUse{{Template: "dockerfile"}},
+	}
+
+	extended := extendTemplateFile(base, TemplateFile{
+		Name:    "grpc-service",
+		Extends: "service",
+		Input: TemplateInputs{
+			"port":  {Type: "string", Default: "9090"},
+			"proto": {Type: "string"},
+		},
+		Files: map[string]TemplateFileConfig{
+			"README.md": {Rename: "README.md"},
+		},
+		Uses: []TemplateUse{{Template: "buf"}},
+	})
+
+	assert.Equal(t, "grpc-service", extended.Name)
+	assert.Equal(t, "a service", extended.Description)
+	assert.Equal(t, []string{"go"}, extended.Tags)
+	assert.Empty(t, extended.Version, "the version belongs to the template itself")
+	assert.Equal(t, "services", extended.Default.Path)
+	assert.Equal(t, TemplateInputs{
+		"name":  {Type: "string"},
+		"port":  {Type: "string", Default: "9090"},
+		"proto": {Type: "string"},
+	}, extended.Input)
+	assert.Equal(t, map[string]TemplateFileConfig{
+		"main.go":   {Rename: "cmd/main.go"},
+		"README.md": {Rename: "README.md"},
+	}, extended.Files)
+	assert.Equal(t, []TemplateUse{{Template: "dockerfile"}, {Template: "buf"}}, extended.Uses)
+}
+
+func TestTemplateIndexerResolveExtends(t *testing.T) {
+	indexer := NewTemplateIndexer()
+	templates := indexer.Resolve([]Template{
+		{Registry: "team", File: TemplateFile{Name: "grpc", Extends: "service"}},
+		{Registry: "team", File: TemplateFile{Name: "service", Extends: "base"}},
+		{Registry: "team", File: TemplateFile{Name: "base", Input: TemplateInputs{"name": {Type: "string"}}}},
+		{Registry: "team", Path: "/registry/missing", File: TemplateFile{Name: "missing", Extends: "unknown"}},
+		{Registry: "team", Path: "/registry/a", File: TemplateFile{Name: "a", Extends: "b"}},
+		{Registry: "team", Path: "/registry/b", File: TemplateFile{Name: "b", Extends: "a"}},
+	})
+
+	require.Len(t, templates, 3)
+	grpc := templates[0]
+	require.NotNil(t, grpc.Base)
+	assert.Equal(t, "team/service", grpc.Base.FullName())
+	require.NotNil(t, grpc.Base.Base)
+	assert.Equal(t, "team/base", grpc.Base.Base.FullName())
+	assert.Contains(t, grpc.File.Input, "name", "inputs are inherited through the whole chain")
+
+	diagnostics := indexer.Diagnostics()
+	require.Len(t, diagnostics, 3)
+	assert.ErrorContains(t, diagnostics[0], "template extends itself: team/a -> team/b -> team/a")
+	assert.ErrorContains(t, diagnostics[1], "template extends itself: team/b -> team/a -> team/b")
+	assert.ErrorContains(t, diagnostics[2], "extends: template: unknown not found")
+}
+
+func TestTemplateLoaderLoadExtends(t *testing.T) {
+	registryPath := writeTestRegistry(t, map[string]string{
+		"service/scaffold.yaml":   "name: service\ninput:\n  name:\n    type: string\nfiles:\n  main.go:\n    rename: cmd/{{ .Input.name }}/main.go\n",
+		"service/files/main.go":   "package main\n",
+		"service/files/README.md": "# {{ .Input.name }}\n",
+		"grpc/scaffold.yaml":      "name: grpc\nextends: service\n",
+		"grpc/files/README.md":    "# {{ .Input.name }} (grpc)\n",
+		"grpc/files/api.proto":    "syntax = \"proto3\";\n",
+		"worker/scaffold.yaml":    "name: worker\nextends: service\nfiles:\n  main.go:\n    rename: worker.go\n",
+	})
+
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	templates, err := NewTemplateIndexer().Index(ctx, registryPath, ui)
+	require.NoError(t, err)
+
+	byName := make(map[string]*Template)
+	for i := range templates {
+		byName[templates[i].File.Name] = &templates[i]
+	}
+
+	loader := NewTemplateLoader(ui)
+	dest := t.TempDir()
+
+	tests := []struct {
+		template string
+		expected map[string]string
+	}{
+		{
+			template: "grpc",
+			expected: map[string]string{
+				path.Join(dest, "cmd", "orders", "main.go"): "package main\n",
+				path.Join(dest, "README.md"):                "# orders (grpc)\n",
+				path.Join(dest, "api.proto"):                "syntax = \"proto3\";\n",
+			},
+		},
+		{
+			template: "worker",
+			expected: map[string]string{
+				path.Join(dest, "worker.go"): "package main\n",
+				path.Join(dest, "README.md"): "# orders\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			template := byName[test.template]
+			require.NotNil(t, template)
+			template.Input["name"] = "orders"
+
+			files, err := loader.Load(ctx, template)
+			require.NoError(t, err)
+
+			templatedFiles, err := loader.TemplateFiles(template, files, dest)
+			require.NoError(t, err)
+
+			actual := make(map[string]string)
+			for _, file := range templatedFiles {
+				actual[file.DestinationPath] = string(file.Content)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// insertGo adds content to the go declaration given by target, the existing file is parsed to find where the content goes, and then re-formatted. If the content is already present the existing file is returned as is
+func insertGo(mode TemplatedFileWriteMode, existing []byte, target string, content []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", existing, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse go file: %w", err)
+	}
+
+	content = bytes.TrimSpace(content)
+
+	var insertAt int
+	switch mode {
+	case TemplatedFileWriteModeGoImport:
+		return insertGoImports(fset, file, existing, content)
+	case TemplatedFileWriteModeGoFunc:
+		funcDecl := findFunc(file, target)
+		if funcDecl == nil || funcDecl.Body == nil {
+			return nil, fmt.Errorf("function: '%s' was not found", target)
+		}
+
+		statements, err := parseGoStatements(content)
+		if err != nil {
+			return nil, err
+		}
+
+		if containsStatements(fset, funcDecl.Body.List, statements) {
+			return existing, nil
+		}
+
+		// Statements are added before a final return, as they'd otherwise be unreachable
+		insertAt = fset.Position(funcDecl.Body.Rbrace).Offset
+		if statements := funcDecl.Body.List; len(statements) > 0 {
+			if returnStmt, ok := statements[len(statements)-1].(*ast.ReturnStmt); ok {
+				insertAt = fset.Position(returnStmt.Pos()).Offset
+			}
+		}
+	case TemplatedFileWriteModeGoStruct:
+		structType, ok := findType(file, target).(*ast.StructType)
+		if !ok {
+			return nil, fmt.Errorf("struct: '%s' was not found", target)
+		}
+
+		missing, err := missingFields("field", target, fset, structType.Fields, fmt.Sprintf("package fields\ntype fields struct {\n%s\n}\n", content))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(missing) == 0 {
+			return existing, nil
+		}
+
+		content = missing
+		insertAt = fset.Position(structType.Fields.Closing).Offset
+	case TemplatedFileWriteModeGoInterface:
+		interfaceType, ok := findType(file, target).(*ast.InterfaceType)
+		if !ok {
+			return nil, fmt.Errorf("interface: '%s' was not found", target)
+		}
+
+		missing, err := missingFields("method", target, fset, interfaceType.Methods, fmt.Sprintf("package methods\ntype methods interface {\n%s\n}\n", content))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(missing) == 0 {
+			return existing, nil
+		}
+
+		content = missing
+		insertAt = fset.Position(interfaceType.Methods.Closing).Offset
+	default:
+		return nil, fmt.Errorf("not a go write mode: %s", mode)
+	}
+
+	// The content goes on its own line, even if the closing brace shares a line with the opening one
+	snippet := fmt.Sprintf("%s\n", content)
+	if !bytes.HasSuffix(bytes.TrimRight(existing[:insertAt], " \t"), []byte("\n")) {
+		snippet = fmt.Sprintf("\n%s", snippet)
+	}
+
+	return formatReplaced(existing, insertAt, insertAt, snippet)
+}
+
+func insertGoImports(fset *token.FileSet, file *ast.File, existing []byte, content []byte) ([]byte, error) {
+	imports, err := parser.ParseFile(token.NewFileSet(), "", fmt.Sprintf("package imports\nimport (\n%s\n)\n", content), 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse imports, expected one import per line: %w", err)
+	}
+
+	// A package can only be imported once, so an import is present if its path is, whatever it is named
+	existingImports := make(map[string]bool)
+	for _, importSpec := range file.Imports {
+		existingImports[importSpec.Path.Value] = true
+	}
+
+	missing := make([]string, 0)
+	for _, importSpec := range imports.Imports {
+		if existingImports[importSpec.Path.Value] {
+			continue
+		}
+		existingImports[importSpec.Path.Value] = true
+
+		if importSpec.Name != nil {
+			missing = append(missing, fmt.Sprintf("%s %s", importSpec.Name.Name, importSpec.Path.Value))
+		} else {
+			missing = append(missing, importSpec.Path.Value)
+		}
+	}
+
+	if len(missing) == 0 {
+		return existing, nil
+	}
+
+	importLines := strings.Join(missing, "\n")
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+
+		rparen := fset.Position(genDecl.Rparen).Offset
+		if genDecl.Lparen.IsValid() {
+			return formatReplaced(existing, rparen, rparen, fmt.Sprintf("\n%s\n", importLines))
+		}
+
+		// A single import is turned into an import group
+		spec := genDecl.Specs[0]
+		return formatReplaced(
+			existing,
+			fset.Position(genDecl.Pos()).Offset, fset.Position(genDecl.End()).Offset,
+			fmt.Sprintf("import (\n%s\n%s\n)", nodeSource(fset, existing, spec), importLines),
+		)
+	}
+
+	packageEnd := fset.Position(file.Name.End()).Offset
+	return formatReplaced(existing, packageEnd, packageEnd, fmt.Sprintf("\n\nimport (\n%s\n)", importLines))
+}
+
+// findFunc finds a function by name, methods are given as `Type.Method`
+func findFunc(file *ast.File, name string) *ast.FuncDecl {
+	receiver, funcName, isMethod := strings.Cut(name, ".")
+	if !isMethod {
+		funcName = receiver
+	}
+
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Name.Name != funcName {
+			continue
+		}
+
+		if !isMethod && funcDecl.Recv == nil {
+			return funcDecl
+		}
+
+		if isMethod && funcDecl.Recv != nil && len(funcDecl.Recv.List) == 1 && receiverName(funcDecl.Recv.List[0].Type) == receiver {
+			return funcDecl
+		}
+	}
+
+	return nil
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+
+	return ""
+}
+
+func findType(file *ast.File, name string) ast.Expr {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == name {
+				return typeSpec.Type
+			}
+		}
+	}
+
+	return nil
+}
+
+func nodeSource(fset *token.FileSet, source []byte, node ast.Node) []byte {
+	return source[fset.Position(node.Pos()).Offset:fset.Position(node.End()).Offset]
+}
+
+// goCode formats a node without its comments, so code from different files can be compared regardless of how it is formatted
+func goCode(fset *token.FileSet, node ast.Node) string {
+	output := bytes.NewBufferString("")
+	if err := format.Node(output, fset, node); err != nil {
+		return ""
+	}
+
+	return output.String()
+}
+
+// parseGoStatements parses content as the statements of a function body
+func parseGoStatements(content []byte) ([]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", fmt.Sprintf("package statements\nfunc statements() {\n%s\n}\n", content), 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse function statements: %w", err)
+	}
+
+	body := file.Decls[0].(*ast.FuncDecl).Body
+	statements := make([]string, 0, len(body.List))
+	for _, statement := range body.List {
+		statements = append(statements, goCode(fset, statement))
+	}
+
+	return statements, nil
+}
+
+// containsStatements tells whether the statements are already in the function body, in the same order and next to each other
+func containsStatements(fset *token.FileSet, body []ast.Stmt, statements []string) bool {
+	if len(statements) == 0 {
+		return true
+	}
+
+	existing := make([]string, 0, len(body))
+	for _, statement := range body {
+		existing = append(existing, goCode(fset, statement))
+	}
+
+	for i := 0; i+len(statements) <= len(existing); i++ {
+		if slices.Equal(existing[i:i+len(statements)], statements) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fieldKeys returns the struct fields or interface methods by name, with their type and tag. Embedded types are keyed by their type
+func fieldKeys(fset *token.FileSet, field *ast.Field) map[string]string {
+	fieldType := goCode(fset, field.Type)
+	if field.Tag != nil {
+		fieldType = fmt.Sprintf("%s %s", fieldType, field.Tag.Value)
+	}
+
+	if len(field.Names) == 0 {
+		return map[string]string{fieldType: fieldType}
+	}
+
+	keys := make(map[string]string, len(field.Names))
+	for _, name := range field.Names {
+		keys[name.Name] = fieldType
+	}
+
+	return keys
+}
+
+// missingFields parses source, a struct or interface wrapping the content, and returns the source of the fields or methods which aren't in existing yet, with their comments.
+// A field which exists with another type can't be added, as the struct or interface would no longer compile
+func missingFields(kind string, target string, fset *token.FileSet, existing *ast.FieldList, source string) ([]byte, error) {
+	contentFset := token.NewFileSet()
+	file, err := parser.ParseFile(contentFset, "", source, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %ss: %w", kind, err)
+	}
+
+	existingFields := make(map[string]string)
+	for _, field := range existing.List {
+		maps.Copy(existingFields, fieldKeys(fset, field))
+	}
+
+	var fields *ast.FieldList
+	switch t := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(type) {
+	case *ast.StructType:
+		fields = t.Fields
+	case *ast.InterfaceType:
+		fields = t.Methods
+	}
+
+	missing := make([]string, 0)
+	for _, field := range fields.List {
+		present := 0
+		keys := fieldKeys(contentFset, field)
+		for name, fieldType := range keys {
+			existingType, ok := existingFields[name]
+			if !ok {
+				continue
+			}
+
+			if existingType != fieldType {
+				return nil, fmt.Errorf("%s: '%s' is already in '%s' as: %s", kind, name, target, existingType)
+			}
+
+			present++
+		}
+
+		switch present {
+		case len(keys):
+			continue
+		case 0:
+		default:
+			return nil, fmt.Errorf("%ss: '%s' are partly in '%s' already", kind, goCode(contentFset, field.Type), target)
+		}
+
+		start, end := field.Pos(), field.End()
+		if field.Doc != nil {
+			start = field.Doc.Pos()
+		}
+		if field.Comment != nil {
+			end = field.Comment.End()
+		}
+		missing = append(missing, source[contentFset.Position(start).Offset:contentFset.Position(end).Offset])
+	}
+
+	return []byte(strings.Join(missing, "\n")), nil
+}
+
+// formatReplaced replaces existing[start:end] with snippet, and formats the result
+func formatReplaced(existing []byte, start, end int, snippet string) ([]byte, error) {
+	output := make([]byte, 0, len(existing)+len(snippet))
+	output = append(output, existing[:start]...)
+	output = append(output, snippet...)
+	output = append(output, existing[end:]...)
+
+	formatted, err := format.Source(output)
+	if err != nil {
+		return nil, fmt.Errorf("inserted content didn't result in valid go: %w", err)
+	}
+
+	return formatted, nil
+}
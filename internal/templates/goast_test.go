@@ -0,0 +1,127 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const goFile = `package app
+
+import "context"
+
+type Config struct {
+	Name string
+}
+
+type Service interface {
+	Run(ctx context.Context) error
+}
+
+type server struct{}
+
+func (s *server) Setup() error {
+	s.routes()
+
+	return nil
+}
+
+func main() {
+	run()
+}
+`
+
+func TestInsertGo(t *testing.T) {
+	t.Run("imports", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoImport, []byte(goFile), "", []byte("\"context\"\n\"net/http\"\nchi \"github.com/go-chi/chi/v5\"\n"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(output), "import (\n\t\"context\"\n\tchi \"github.com/go-chi/chi/v5\"\n\t\"net/http\"\n)\n")
+	})
+
+	t.Run("imports are compared by path", func(t *testing.T) {
+		existing := "package app\n\nimport f \"fmt\"\n\nvar _ = f.Sprint\n"
+		output, err := insertGo(TemplatedFileWriteModeGoImport, []byte(existing), "", []byte("\"fmt\"\nstrs \"strings\"\n\"strings\"\n"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "package app\n\nimport (\n\tf \"fmt\"\n\tstrs \"strings\"\n)\n\nvar _ = f.Sprint\n", string(output))
+	})
+
+	t.Run("function statements", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoFunc, []byte(goFile), "main", []byte("runHttp()"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(output), "func main() {\n\trun()\n\trunHttp()\n}\n")
+	})
+
+	t.Run("method statements before return", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoFunc, []byte(goFile), "server.Setup", []byte("s.middleware()"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(output), "\ts.routes()\n\n\ts.middleware()\n\treturn nil\n}")
+	})
+
+	t.Run("struct fields", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoStruct, []byte(goFile), "Config", []byte("Port int"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(output), "type Config struct {\n\tName string\n\tPort int\n}\n")
+	})
+
+	t.Run("interface methods", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoInterface, []byte(goFile), "Service", []byte("Stop() error"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(output), "\tRun(ctx context.Context) error\n\tStop() error\n}\n")
+	})
+
+	t.Run("already present", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoStruct, []byte(goFile), "Config", []byte("Name   string"))
+		require.NoError(t, err)
+
+		assert.Equal(t, goFile, string(output))
+	})
+
+	t.Run("statements are compared as code", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoFunc, []byte(goFile), "server.Setup", []byte("s.routes( )\nreturn nil"))
+		require.NoError(t, err)
+		assert.Equal(t, goFile, string(output))
+
+		output, err = insertGo(TemplatedFileWriteModeGoFunc, []byte(goFile), "server.Setup", []byte("routes()"))
+		require.NoError(t, err)
+		assert.Contains(t, string(output), "\ts.routes()\n\n\troutes()\n\treturn nil\n}", "a part of a statement isn't the statement")
+	})
+
+	t.Run("only missing fields are added", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoStruct, []byte(goFile), "Config", []byte("Name string\n// Port to listen on\nPort int `yaml:\"port\"` // defaults to 8080"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(output), "type Config struct {\n\tName string\n\t// Port to listen on\n\tPort int `yaml:\"port\"` // defaults to 8080\n}\n")
+	})
+
+	t.Run("fields with another type conflict", func(t *testing.T) {
+		_, err := insertGo(TemplatedFileWriteModeGoStruct, []byte(goFile), "Config", []byte("Name int"))
+		assert.ErrorContains(t, err, "field: 'Name' is already in 'Config' as: string")
+
+		withPort, err := insertGo(TemplatedFileWriteModeGoStruct, []byte(goFile), "Config", []byte("Port int64"))
+		require.NoError(t, err)
+
+		_, err = insertGo(TemplatedFileWriteModeGoStruct, withPort, "Config", []byte("Port int"))
+		assert.ErrorContains(t, err, "field: 'Port' is already in 'Config' as: int64")
+	})
+
+	t.Run("methods are compared by name and signature", func(t *testing.T) {
+		output, err := insertGo(TemplatedFileWriteModeGoInterface, []byte(goFile), "Service", []byte("Run(ctx context.Context)  error"))
+		require.NoError(t, err)
+		assert.Equal(t, goFile, string(output))
+
+		_, err = insertGo(TemplatedFileWriteModeGoInterface, []byte(goFile), "Service", []byte("Run() error"))
+		assert.ErrorContains(t, err, "method: 'Run' is already in 'Service'")
+	})
+
+	t.Run("missing target", func(t *testing.T) {
+		_, err := insertGo(TemplatedFileWriteModeGoFunc, []byte(goFile), "server.Missing", []byte("run()"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,265 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	gotmpl "text/template"
+	"text/template/parse"
+
+	"github.com/iancoleman/strcase"
+)
+
+type HookStage string
+
+const (
+	HookStagePre  HookStage = "pre"
+	HookStagePost HookStage = "post"
+)
+
+type PromptConfirmHooks func(template *Template, hooks TemplateHooks) (bool, error)
+
+// HookRunner runs the pre and post generation hooks of a template, as templates come from a remote registry the hooks are shown, and optionally confirmed before they're run
+type HookRunner struct {
+	disabled      bool
+	promptConfirm PromptConfirmHooks
+}
+
+func NewHookRunner() *HookRunner {
+	return &HookRunner{
+		disabled:      false,
+		promptConfirm: nil,
+	}
+}
+
+func (h *HookRunner) WithDisabled(disabled bool) *HookRunner {
+	h.disabled = disabled
+
+	return h
+}
+
+func (h *HookRunner) WithPromptConfirm(pc PromptConfirmHooks) *HookRunner {
+	h.promptConfirm = pc
+
+	return h
+}
+
+// Plan templates the hook commands and lists them for the user to confirm. If the hooks are disabled or declined no hooks are returned
+func (h *HookRunner) Plan(ui *slog.Logger, template *Template) (TemplateHooks, error) {
+	if len(template.File.Hooks.Pre) == 0 && len(template.File.Hooks.Post) == 0 {
+		return TemplateHooks{}, nil
+	}
+
+	if h.disabled {
+		ui.Warn("Skipping hooks as they're disabled", "template", template.File.Name)
+		return TemplateHooks{}, nil
+	}
+
+	pre, err := templateHooks(template, template.File.Hooks.Pre)
+	if err != nil {
+		return TemplateHooks{}, err
+	}
+
+	post, err := templateHooks(template, template.File.Hooks.Post)
+	if err != nil {
+		return TemplateHooks{}, err
+	}
+
+	hooks := TemplateHooks{
+		Pre:  pre,
+		Post: post,
+	}
+
+	ui.Info("Template has hooks", "template", template.File.Name)
+	for _, command := range hooks.Pre {
+		ui.Info("hook", "stage", HookStagePre, "command", command)
+	}
+	for _, command := range hooks.Post {
+		ui.Info("hook", "stage", HookStagePost, "command", command)
+	}
+
+	if h.promptConfirm != nil {
+		confirmed, err := h.promptConfirm(template, hooks)
+		if err != nil {
+			return TemplateHooks{}, fmt.Errorf("failed to get answer to whether hooks should be run or not: %w", err)
+		}
+
+		if !confirmed {
+			ui.Warn("Skipping hooks", "template", template.File.Name)
+			return TemplateHooks{}, nil
+		}
+	}
+
+	return hooks, nil
+}
+
+// Run executes the commands in order using the shell, the working directory is set to the scaffold destination, and the inputs are passed as SCAFFOLD_INPUT_<NAME> environment variables
+func (h *HookRunner) Run(ctx context.Context, ui *slog.Logger, template *Template, stage HookStage, commands []string, scaffoldDest string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+
+	// A template without a default path is scaffolded into the current directory
+	if scaffoldDest == "" {
+		scaffoldDest = "."
+	}
+
+	if err := os.MkdirAll(scaffoldDest, readWriteExec); err != nil {
+		return fmt.Errorf("failed to create scaffold destination for hooks: %s, %w", scaffoldDest, err)
+	}
+
+	env := hookEnv(template, scaffoldDest)
+	for _, command := range commands {
+		ui.Info("running hook", "stage", stage, "command", command)
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = scaffoldDest
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook failed: %s, %w", stage, command, err)
+		}
+	}
+
+	return nil
+}
+
+// PlannedHooks are the confirmed hooks of a template, and the destination the template is scaffolded into
+type PlannedHooks struct {
+	Template    *Template
+	Destination string
+	Hooks       TemplateHooks
+}
+
+// PlanAll plans the hooks of the template and of the templates it uses, each of them is confirmed on its own, see Plan
+func (h *HookRunner) PlanAll(ui *slog.Logger, template *Template, scaffoldDest string, usedTemplates []UsedTemplate) ([]PlannedHooks, error) {
+	templates := slices.Concat([]UsedTemplate{{Template: template, Destination: scaffoldDest}}, usedTemplates)
+
+	planned := make([]PlannedHooks, 0, len(templates))
+	for _, used := range templates {
+		hooks, err := h.Plan(ui, used.Template)
+		if err != nil {
+			return nil, fmt.Errorf("template: %s, %w", used.Template.FullName(), err)
+		}
+
+		planned = append(planned, PlannedHooks{Template: used.Template, Destination: used.Destination, Hooks: hooks})
+	}
+
+	return planned, nil
+}
+
+// RunAll runs the stage of the planned hooks, the hooks of the template first, followed by the templates it uses, each in its own destination
+func (h *HookRunner) RunAll(ctx context.Context, ui *slog.Logger, stage HookStage, planned []PlannedHooks) error {
+	for _, plannedHooks := range planned {
+		commands := plannedHooks.Hooks.Pre
+		if stage == HookStagePost {
+			commands = plannedHooks.Hooks.Post
+		}
+
+		if err := h.Run(ctx, ui, plannedHooks.Template, stage, commands, plannedHooks.Destination); err != nil {
+			return fmt.Errorf("template: %s, %w", plannedHooks.Template.FullName(), err)
+		}
+	}
+
+	return nil
+}
+
+// shellQuoteFunc is the template func every value inserted into a hook is piped through
+const shellQuoteFunc = "shellQuote"
+
+var hookFuncs = func() gotmpl.FuncMap {
+	hookFuncs := maps.Clone(funcs)
+	hookFuncs[shellQuoteFunc] = shellQuote
+
+	return hookFuncs
+}()
+
+// shellQuote wraps the value in single quotes, so the shell uses it as a single word as it is
+func shellQuote(value any) string {
+	return "'" + strings.ReplaceAll(fmt.Sprint(value), "'", `'\''`) + "'"
+}
+
+// templateHooks templates the hook commands. As the commands are run by the shell, every value inserted into them is quoted, so an input can't break out of the command
+func templateHooks(template *Template, commands []string) ([]string, error) {
+	templated := make([]string, 0, len(commands))
+	for _, command := range commands {
+		tmpl, err := gotmpl.New("hook").Funcs(hookFuncs).Parse(command)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse hook: %s, %w", command, err)
+		}
+		quoteActions(tmpl.Tree.Root)
+
+		output := bytes.NewBufferString("")
+		if err := tmpl.Execute(output, template); err != nil {
+			return nil, fmt.Errorf("failed to template hook: %s, %w", command, err)
+		}
+
+		templated = append(templated, strings.TrimSpace(output.String()))
+	}
+
+	return templated, nil
+}
+
+// quoteActions pipes every action which prints a value through shellQuote, like html/template escapes what it prints
+func quoteActions(node parse.Node) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+
+		for _, child := range node.Nodes {
+			quoteActions(child)
+		}
+	case *parse.ActionNode:
+		// Variable declarations don't print anything
+		if len(node.Pipe.Decl) > 0 {
+			return
+		}
+
+		node.Pipe.Cmds = append(node.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      node.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(shellQuoteFunc).SetPos(node.Pos)},
+		})
+	case *parse.IfNode:
+		quoteActions(node.List)
+		quoteActions(node.ElseList)
+	case *parse.RangeNode:
+		quoteActions(node.List)
+		quoteActions(node.ElseList)
+	case *parse.WithNode:
+		quoteActions(node.List)
+		quoteActions(node.ElseList)
+	}
+}
+
+func hookEnv(template *Template, scaffoldDest string) []string {
+	env := os.Environ()
+
+	destination, err := filepath.Abs(scaffoldDest)
+	if err != nil {
+		destination = scaffoldDest
+	}
+
+	env = append(env,
+		fmt.Sprintf("SCAFFOLD_TEMPLATE=%s", template.File.Name),
+		fmt.Sprintf("SCAFFOLD_DESTINATION=%s", destination),
+		fmt.Sprintf("SCAFFOLD_REGISTRY_COMMIT=%s", template.Commit),
+	)
+
+	for name, value := range template.Input {
+		env = append(env, fmt.Sprintf("SCAFFOLD_INPUT_%s=%s", strcase.ToScreamingSnake(name), value))
+	}
+
+	return env
+}
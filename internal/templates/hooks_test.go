@@ -0,0 +1,213 @@
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookRunnerPlan(t *testing.T) {
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	template := &Template{
+		File: TemplateFile{
+			Name: "hooks",
+			Hooks: TemplateHooks{
+				Pre:  []string{"mkdir -p {{ .Input.dir }}/{{ ToUpper .Input.dir }}"},
+				Post: []string{"  touch {{ .Input.name }}  ", "{{ $name := .Input.name }}{{ if $name }}echo {{ $name }}{{ end }}"},
+			},
+		},
+		Input: map[string]string{"dir": "migrations", "name": "it's; rm -rf ~"},
+	}
+	templated := TemplateHooks{
+		Pre:  []string{"mkdir -p 'migrations'/'MIGRATIONS'"},
+		Post: []string{`touch 'it'\''s; rm -rf ~'`, `echo 'it'\''s; rm -rf ~'`},
+	}
+
+	tests := []struct {
+		name     string
+		runner   *HookRunner
+		expected TemplateHooks
+		err      string
+	}{
+		{
+			name:     "hooks are templated",
+			runner:   NewHookRunner(),
+			expected: templated,
+		},
+		{
+			name: "confirmed hooks are run",
+			runner: NewHookRunner().WithPromptConfirm(func(_ *Template, hooks TemplateHooks) (bool, error) {
+				assert.Equal(t, templated, hooks, "the templated hooks are confirmed")
+				return true, nil
+			}),
+			expected: templated,
+		},
+		{
+			name: "declined hooks are skipped",
+			runner: NewHookRunner().WithPromptConfirm(func(*Template, TemplateHooks) (bool, error) {
+				return false, nil
+			}),
+			expected: TemplateHooks{},
+		},
+		{
+			name: "disabled hooks aren't confirmed",
+			runner: NewHookRunner().WithDisabled(true).WithPromptConfirm(func(*Template, TemplateHooks) (bool, error) {
+				return false, errors.New("asked")
+			}),
+			expected: TemplateHooks{},
+		},
+		{
+			name: "failing to confirm fails",
+			runner: NewHookRunner().WithPromptConfirm(func(*Template, TemplateHooks) (bool, error) {
+				return false, errors.New("no tty")
+			}),
+			err: "failed to get answer to whether hooks should be run or not: no tty",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hooks, err := test.runner.Plan(ui, template)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, hooks)
+		})
+	}
+
+	t.Run("templated inputs can't break out of the command", func(t *testing.T) {
+		dest := t.TempDir()
+		hooks, err := NewHookRunner().Plan(ui, &Template{
+			File:  TemplateFile{Hooks: TemplateHooks{Post: []string{"printf '%s' {{ .Input.name }} > name"}}},
+			Input: map[string]string{"name": "orders; touch injected"},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, NewHookRunner().Run(t.Context(), ui, &Template{}, HookStagePost, hooks.Post, dest))
+		name, err := os.ReadFile(path.Join(dest, "name"))
+		require.NoError(t, err)
+		assert.Equal(t, "orders; touch injected", string(name))
+		assert.NoFileExists(t, path.Join(dest, "injected"))
+	})
+
+	t.Run("invalid hooks fail", func(t *testing.T) {
+		_, err := NewHookRunner().Plan(ui, &Template{File: TemplateFile{Hooks: TemplateHooks{Pre: []string{"{{ .Input.name"}}}})
+		assert.ErrorContains(t, err, "failed to parse hook")
+	})
+}
+
+func TestHookRunnerRun(t *testing.T) {
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	template := &Template{
+		File:   TemplateFile{Name: "hooks"},
+		Commit: "abc123",
+		Input:  map[string]string{"serviceName": "orders; echo injected"},
+	}
+
+	dest := path.Join(t.TempDir(), "orders")
+	require.NoError(t, NewHookRunner().Run(ctx, ui, template, HookStagePost, []string{
+		`printf '%s' "$SCAFFOLD_INPUT_SERVICE_NAME" > input`,
+		`printf '%s %s' "$SCAFFOLD_TEMPLATE" "$SCAFFOLD_REGISTRY_COMMIT" > template`,
+	}, dest))
+
+	input, err := os.ReadFile(path.Join(dest, "input"))
+	require.NoError(t, err)
+	assert.Equal(t, "orders; echo injected", string(input), "the destination is created, and the hooks run in it")
+
+	env, err := os.ReadFile(path.Join(dest, "template"))
+	require.NoError(t, err)
+	assert.Equal(t, "hooks abc123", string(env))
+
+	err = NewHookRunner().Run(ctx, ui, template, HookStagePre, []string{"exit 3", "touch never"}, dest)
+	assert.ErrorContains(t, err, "pre hook failed: exit 3")
+	assert.NoFileExists(t, path.Join(dest, "never"), "the hooks after a failing hook aren't run")
+
+	t.Run("an empty destination is the current directory", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+
+		require.NoError(t, NewHookRunner().Run(ctx, ui, template, HookStagePost, []string{"touch created"}, ""))
+		assert.FileExists(t, "created")
+	})
+}
+
+func TestHookRunnerPlanAll(t *testing.T) {
+	registryPath := writeTestRegistry(t, map[string]string{
+		"service/scaffold.yaml": `name: service
+input:
+  name:
+    type: string
+hooks:
+  post:
+    - printf '%s' {{ .Input.name }} > service
+uses:
+  - template: dockerfile
+    input:
+      binary: "{{ .Input.name }}-server"
+`,
+		"dockerfile/scaffold.yaml": `name: dockerfile
+default:
+  path: deploy
+input:
+  binary:
+    type: string
+hooks:
+  pre:
+    - printf '%s' {{ .Input.binary }} > binary
+uses:
+  - template: license
+`,
+		"license/scaffold.yaml": "name: license\nhooks:\n  post:\n    - touch license\n",
+	})
+
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	templates, err := NewTemplateIndexer().Index(ctx, registryPath, ui)
+	require.NoError(t, err)
+
+	var service *Template
+	for i := range templates {
+		if templates[i].File.Name == "service" {
+			service = &templates[i]
+		}
+	}
+	require.NotNil(t, service)
+	service.Input["name"] = "orders"
+
+	dest := t.TempDir()
+	usedTemplates, err := NewTemplateLoader(ui).ResolveDependencies(service, dest)
+	require.NoError(t, err)
+
+	confirmed := make([]string, 0)
+	hookRunner := NewHookRunner().WithPromptConfirm(func(template *Template, _ TemplateHooks) (bool, error) {
+		confirmed = append(confirmed, template.File.Name)
+		return template.File.Name != "license", nil
+	})
+
+	planned, err := hookRunner.PlanAll(ui, service, dest, usedTemplates)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"service", "dockerfile", "license"}, confirmed, "the hooks of every used template are confirmed")
+
+	require.NoError(t, hookRunner.RunAll(ctx, ui, HookStagePre, planned))
+	require.NoError(t, hookRunner.RunAll(ctx, ui, HookStagePost, planned))
+
+	name, err := os.ReadFile(path.Join(dest, "service"))
+	require.NoError(t, err)
+	assert.Equal(t, "orders", string(name))
+
+	binary, err := os.ReadFile(path.Join(dest, "deploy", "binary"))
+	require.NoError(t, err)
+	assert.Equal(t, "orders-server", string(binary), "the hooks of a used template run in its destination, with its inputs")
+
+	assert.NoFileExists(t, path.Join(dest, "deploy", "license"), "declined hooks of a used template aren't run")
+}
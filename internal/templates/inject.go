@@ -0,0 +1,93 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// InjectPosition is where injected content goes relative to the line containing the anchor
+type InjectPosition string
+
+const (
+	InjectPositionBefore InjectPosition = "before"
+	InjectPositionAfter  InjectPosition = "after"
+)
+
+// ParseInjectPosition parses a position as given in scaffold.yaml, an empty position is after the anchor
+func ParseInjectPosition(position string) (InjectPosition, error) {
+	switch InjectPosition(position) {
+	case "", InjectPositionAfter:
+		return InjectPositionAfter, nil
+	case InjectPositionBefore:
+		return InjectPositionBefore, nil
+	default:
+		return "", fmt.Errorf("invalid inject position: '%s', must be either %s or %s", position, InjectPositionBefore, InjectPositionAfter)
+	}
+}
+
+// inject inserts content on its own lines before or after the first line ending with anchor, i.e. `// scaffold:routes`
+func inject(existing []byte, anchor string, position InjectPosition, content []byte) ([]byte, error) {
+	if anchor == "" {
+		return nil, fmt.Errorf("no anchor given to inject at")
+	}
+
+	anchorIndex := findAnchor(existing, anchor)
+	if anchorIndex == -1 {
+		return nil, fmt.Errorf("anchor: '%s' was not found", anchor)
+	}
+
+	// Leading newlines are usually left over from the WriteModeInject call at the top of the file
+	content = bytes.TrimLeft(content, "\r\n")
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+
+	var insertAt int
+	switch position {
+	case InjectPositionBefore:
+		insertAt = bytes.LastIndexByte(existing[:anchorIndex], '\n') + 1
+	case InjectPositionAfter, "":
+		lineEnd := bytes.IndexByte(existing[anchorIndex:], '\n')
+		if lineEnd == -1 {
+			// The anchor is on the last line without a trailing newline
+			existing = append(existing, '\n')
+			insertAt = len(existing)
+		} else {
+			insertAt = anchorIndex + lineEnd + 1
+		}
+	default:
+		return nil, fmt.Errorf("invalid inject position: '%s', must be either %s or %s", position, InjectPositionBefore, InjectPositionAfter)
+	}
+
+	output := make([]byte, 0, len(existing)+len(content))
+	output = append(output, existing[:insertAt]...)
+	output = append(output, content...)
+	output = append(output, existing[insertAt:]...)
+
+	return output, nil
+}
+
+// findAnchor returns the index of the anchor in existing, or -1. The anchor has to end its line and start at the beginning of the line or after whitespace, so `// scaffold:routes` doesn't match `// scaffold:routes-admin`
+func findAnchor(existing []byte, anchor string) int {
+	lineStart := 0
+	for lineStart <= len(existing) {
+		lineEnd := bytes.IndexByte(existing[lineStart:], '\n')
+		if lineEnd == -1 {
+			lineEnd = len(existing)
+		} else {
+			lineEnd += lineStart
+		}
+
+		line := bytes.TrimRight(existing[lineStart:lineEnd], " \t\r")
+		if bytes.HasSuffix(line, []byte(anchor)) {
+			anchorStart := len(line) - len(anchor)
+			if anchorStart == 0 || line[anchorStart-1] == ' ' || line[anchorStart-1] == '\t' {
+				return lineStart + anchorStart
+			}
+		}
+
+		lineStart = lineEnd + 1
+	}
+
+	return -1
+}
@@ -0,0 +1,86 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInject(t *testing.T) {
+	existing := "package main\n\nfunc main() {\n\t// scaffold:routes\n}\n"
+
+	t.Run("after anchor", func(t *testing.T) {
+		output, err := inject([]byte(existing), "// scaffold:routes", InjectPositionAfter, []byte("\n\troutes.Add()"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "package main\n\nfunc main() {\n\t// scaffold:routes\n\troutes.Add()\n}\n", string(output))
+	})
+
+	t.Run("before anchor", func(t *testing.T) {
+		output, err := inject([]byte(existing), "// scaffold:routes", InjectPositionBefore, []byte("\troutes.Add()\n"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "package main\n\nfunc main() {\n\troutes.Add()\n\t// scaffold:routes\n}\n", string(output))
+	})
+
+	t.Run("anchor on last line", func(t *testing.T) {
+		output, err := inject([]byte("# scaffold:entries"), "# scaffold:entries", InjectPositionAfter, []byte("entry"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "# scaffold:entries\nentry\n", string(output))
+	})
+
+	t.Run("anchor which is a prefix of another anchor", func(t *testing.T) {
+		existing := "func main() {\n\t// scaffold:routes-admin\n\tadmin()\n\t// scaffold:routes  \n}\n"
+
+		output, err := inject([]byte(existing), "// scaffold:routes", InjectPositionAfter, []byte("\troutes.Add()\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "func main() {\n\t// scaffold:routes-admin\n\tadmin()\n\t// scaffold:routes  \n\troutes.Add()\n}\n", string(output))
+
+		_, err = inject([]byte("// scaffold:routes-admin\n"), "// scaffold:routes", InjectPositionAfter, []byte("routes.Add()"))
+		assert.ErrorContains(t, err, "anchor: '// scaffold:routes' was not found")
+
+		_, err = inject([]byte("x// scaffold:routes\n"), "// scaffold:routes", InjectPositionAfter, []byte("routes.Add()"))
+		assert.Error(t, err, "the anchor starts after whitespace")
+
+		output, err = inject([]byte("routes := []string{} // scaffold:routes"), "// scaffold:routes", InjectPositionBefore, []byte("// routes"))
+		require.NoError(t, err)
+		assert.Equal(t, "// routes\nroutes := []string{} // scaffold:routes", string(output), "the anchor can end a line of code")
+	})
+
+	t.Run("missing anchor", func(t *testing.T) {
+		_, err := inject([]byte(existing), "// scaffold:handlers", InjectPositionAfter, []byte("handler"))
+		assert.Error(t, err)
+	})
+}
+
+func TestParseInjectPosition(t *testing.T) {
+	position, err := ParseInjectPosition("")
+	require.NoError(t, err)
+	assert.Equal(t, InjectPositionAfter, position)
+
+	position, err = ParseInjectPosition("before")
+	require.NoError(t, err)
+	assert.Equal(t, InjectPositionBefore, position)
+
+	_, err = ParseInjectPosition("above")
+	assert.ErrorContains(t, err, "invalid inject position: 'above', must be either before or after")
+}
+
+func TestAlreadyApplied(t *testing.T) {
+	existing := []byte("package main\n\nfunc main() {\n\t// scaffold:begin users\n\troutes.Add(\"/users\")\n\t// scaffold:end users\n\troutes.Add(\"/orders\")\n}\n")
+
+	assert.True(t, alreadyApplied(existing, []byte("\troutes.Add(\"/orders\")\n")))
+	assert.False(t, alreadyApplied(existing, []byte("\troutes.Add(\"/items\")\n")))
+	assert.True(t, alreadyApplied(existing, []byte("// scaffold:begin users\nroutes.Add(\"/users\", v2)\n// scaffold:end users\n")))
+	assert.False(t, alreadyApplied(existing, []byte("// scaffold:begin items\nroutes.Add(\"/users\")\n// scaffold:end items\n")))
+	assert.False(t, alreadyApplied(existing, []byte("\n\n")))
+
+	assert.True(t, alreadyApplied(existing, []byte("routes.Add(\"/users\")\n// scaffold:end users\n")), "lines are matched regardless of their indentation")
+	assert.False(t, alreadyApplied(existing, []byte("Add(\"/orders\")\n")), "a part of a line isn't applied")
+	assert.False(t, alreadyApplied([]byte("barfoo()\n"), []byte("foo()\n")))
+	assert.False(t, alreadyApplied([]byte("foo()\nbar()\n"), []byte("foo()\nbaz()\n")), "all the lines have to be present together")
+	assert.False(t, alreadyApplied([]byte("// scaffold:begin users2\nusers\n// scaffold:end users2\n"), []byte("// scaffold:begin users\nusers\n// scaffold:end users\n")), "the marker id has to match as a whole")
+	assert.True(t, alreadyApplied([]byte("# scaffold:begin users\n"), []byte("# scaffold:begin users\nusers\n")), "the marker id can end the file")
+}
@@ -0,0 +1,644 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	gotmpl "text/template"
+	"text/template/parse"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+)
+
+// LintSeverity is how bad a lint issue is, errors break the template, while warnings should be looked at
+type LintSeverity string
+
+const (
+	LintSeverityError   LintSeverity = "error"
+	LintSeverityWarning LintSeverity = "warning"
+)
+
+// The rules checked by the Linter, they are part of the output so CI can filter on them
+const (
+	LintRuleInvalidYAML     = "invalid-yaml"
+	LintRuleUnknownKey      = "unknown-key"
+	LintRuleInvalidField    = "invalid-field"
+	LintRuleMissingFiles    = "missing-files"
+	LintRuleUnmatchedFile   = "unmatched-file"
+	LintRuleParseError      = "parse-error"
+	LintRuleUndeclaredInput = "undeclared-input"
+	LintRuleDuplicateName   = "duplicate-name"
+	LintRuleMissingTests    = "missing-tests"
+	// LintRuleUnresolvedTemplate is a template which is extended or used, but can't be found in the registry
+	LintRuleUnresolvedTemplate = "unresolved-template"
+)
+
+// LintIssue is a problem the Linter found in a template
+type LintIssue struct {
+	// Template is the folder of the template, relative to the linted registry
+	Template string `json:"template" yaml:"template"`
+	// File is the file of the template the issue is in, i.e. scaffold.yaml or files/main.go.gotmpl
+	File     string       `json:"file" yaml:"file"`
+	Line     int          `json:"line,omitempty" yaml:"line,omitempty"`
+	Rule     string       `json:"rule" yaml:"rule"`
+	Severity LintSeverity `json:"severity" yaml:"severity"`
+	Message  string       `json:"message" yaml:"message"`
+}
+
+func (i LintIssue) String() string {
+	location := path.Join(i.Template, i.File)
+	if i.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, i.Line)
+	}
+
+	return fmt.Sprintf("%s: %s: %s (%s)", location, i.Severity, i.Message, i.Rule)
+}
+
+// Linter validates templates for their authors, it is stricter than the TemplateIndexer, i.e. unknown keys in scaffold.yaml are errors
+type Linter struct {
+	postProcessors *PostProcessors
+}
+
+func NewLinter() *Linter {
+	return &Linter{
+		postProcessors: DefaultPostProcessors(),
+	}
+}
+
+// WithPostProcessors sets the post processors which templates can refer to
+func (l *Linter) WithPostProcessors(postProcessors *PostProcessors) *Linter {
+	l.postProcessors = postProcessors
+
+	return l
+}
+
+// lintResolution is the template as extended by the indexer, or why it couldn't be, so what it inherits is taken into account
+type lintResolution struct {
+	extended   *Template
+	extendsErr error
+	// usesErrs are the templates in uses which can't be found in the registry, they may be in one of the other registries
+	usesErrs map[int]error
+}
+
+// resolveLinted extends the templates of the registry like the indexer does, by their folder relative to the registry
+func resolveLinted(ctx context.Context, registryDir string) map[string]lintResolution {
+	resolutions := make(map[string]lintResolution)
+
+	// The templates which can't be indexed are reported by the linter itself
+	templates, _, err := NewTemplateIndexer().index(ctx, registryDir, slog.New(slog.DiscardHandler))
+	if err != nil {
+		return resolutions
+	}
+
+	extendedTemplates := make([]Template, 0, len(templates))
+	for _, template := range templates {
+		templateDir := path.Join(template.Category, path.Base(template.Path))
+
+		extended, err := resolveBase(templates, template, nil)
+		if err != nil {
+			resolutions[templateDir] = lintResolution{extendsErr: err}
+			continue
+		}
+
+		extendedTemplates = append(extendedTemplates, extended)
+	}
+
+	for i, template := range extendedTemplates {
+		resolution := lintResolution{extended: &extendedTemplates[i], usesErrs: make(map[int]error)}
+		for j, use := range template.File.Uses {
+			if _, err := findTemplate(extendedTemplates, &template, use.Template); err != nil {
+				resolution.usesErrs[j] = err
+			}
+		}
+
+		resolutions[path.Join(template.Category, path.Base(template.Path))] = resolution
+	}
+
+	return resolutions
+}
+
+// lintedTemplate is a template which could be read, used to compare the templates with each other
+type lintedTemplate struct {
+	dir      string
+	category string
+	name     string
+}
+
+// Lint lints the template in dir if it has a scaffold.yaml, otherwise all the templates in the registry in dir. The issues are sorted by template and file
+func (l *Linter) Lint(ctx context.Context, dir string, ui *slog.Logger) ([]LintIssue, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to lint: %s, %w", dir, err)
+	}
+
+	registryDir := dir
+	templateDirs := []string{}
+	if _, err := os.Stat(filepath.Join(dir, TemplateFileName)); err == nil {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find template: %s, %w", dir, err)
+		}
+
+		registryDir = filepath.Dir(absDir)
+		templateDirs = append(templateDirs, filepath.Base(absDir))
+	} else {
+		templateDirs, err = findTemplateDirs(dir, ui)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		resolutions     = resolveLinted(ctx, registryDir)
+		templateIssues  = make([][]LintIssue, len(templateDirs))
+		lintedTemplates = make([]*lintedTemplate, len(templateDirs))
+	)
+	egrp, ctx := errgroup.WithContext(ctx)
+	for i, templateDir := range templateDirs {
+		egrp.Go(func() error {
+			ui.Debug("linting template", "template", templateDir)
+
+			lintedTemplates[i], templateIssues[i] = l.lintTemplate(ctx, registryDir, templateDir, resolutions[templateDir])
+
+			return nil
+		})
+	}
+
+	if err := egrp.Wait(); err != nil {
+		return nil, err
+	}
+
+	issues := make([]LintIssue, 0)
+	for _, templateIssue := range templateIssues {
+		issues = append(issues, templateIssue...)
+	}
+	issues = append(issues, lintDuplicateNames(lintedTemplates)...)
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Template != issues[j].Template {
+			return issues[i].Template < issues[j].Template
+		}
+
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+
+		return issues[i].Line < issues[j].Line
+	})
+
+	return issues, nil
+}
+
+// templateLinter collects the issues of a single template
+type templateLinter struct {
+	*Linter
+
+	dir    string
+	path   string
+	issues []LintIssue
+}
+
+func (t *templateLinter) report(file string, line int, rule string, severity LintSeverity, message string, args ...any) {
+	t.issues = append(t.issues, LintIssue{
+		Template: t.dir,
+		File:     file,
+		Line:     line,
+		Rule:     rule,
+		Severity: severity,
+		Message:  fmt.Sprintf(message, args...),
+	})
+}
+
+func (l *Linter) lintTemplate(ctx context.Context, registryDir string, templateDir string, resolution lintResolution) (*lintedTemplate, []LintIssue) {
+	t := &templateLinter{
+		Linter: l,
+		dir:    templateDir,
+		path:   filepath.Join(registryDir, templateDir),
+	}
+
+	templateFile, ok := t.lintTemplateFile()
+	if !ok {
+		return nil, t.issues
+	}
+
+	// What is inherited from the extended template counts as declared, the template is linted as if it couldn't be extended otherwise
+	inputs := templateFile.Input
+	inheritedFiles := make(map[string]bool)
+	if templateFile.Extends != "" {
+		switch {
+		case resolution.extendsErr != nil:
+			t.report(TemplateFileName, 0, LintRuleUnresolvedTemplate, LintSeverityError, "%s", resolution.extendsErr.Error())
+		case resolution.extended != nil && resolution.extended.Base != nil:
+			inputs = resolution.extended.File.Input
+
+			baseFiles, err := NewTemplateLoader(slog.New(slog.DiscardHandler)).Load(ctx, resolution.extended.Base)
+			if err != nil {
+				t.report("files", 0, LintRuleMissingFiles, LintSeverityError, "failed to read files of extended template: %s", err.Error())
+			}
+
+			for _, file := range baseFiles {
+				inheritedFiles[strings.TrimSuffix(file.RelPath, ".gotmpl")] = true
+			}
+		}
+	}
+
+	for i := range templateFile.Uses {
+		if err, ok := resolution.usesErrs[i]; ok {
+			t.report(TemplateFileName, 0, LintRuleUnresolvedTemplate, LintSeverityWarning, "uses[%d]: %s in the registry, it has to be in one of the other registries", i, err.Error())
+		}
+	}
+
+	files, ok := t.lintFiles(templateFile, inheritedFiles)
+
+	declaredInputs := make(map[string]bool, len(inputs))
+	for name := range inputs {
+		declaredInputs[name] = true
+	}
+
+	if templateFile.Default.Path != "" {
+		t.lintGoTemplate(TemplateFileName, "default.path", templateFile.Default.Path, declaredInputs)
+	}
+
+	for _, relPath := range slices.Sorted(maps.Keys(templateFile.Files)) {
+		if rename := templateFile.Files[relPath].Rename; rename != "" {
+			t.lintGoTemplate(TemplateFileName, fmt.Sprintf("files.%s.rename", relPath), rename, declaredInputs)
+		}
+	}
+
+	for i, use := range templateFile.Uses {
+		if use.Template == "" {
+			t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "uses[%d].template is required", i)
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(use.Input)) {
+			t.lintGoTemplate(TemplateFileName, fmt.Sprintf("uses[%d].input.%s", i, name), use.Input[name], declaredInputs)
+		}
+
+		if use.Path != "" {
+			t.lintGoTemplate(TemplateFileName, fmt.Sprintf("uses[%d].path", i), use.Path, declaredInputs)
+		}
+	}
+
+	if ok {
+		for _, file := range files {
+			t.lintGoTemplate(path.Join("files", file.RelPath), "", string(file.content), declaredInputs)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(t.path, "scaffold_test.go")); err != nil {
+		t.report("scaffold_test.go", 0, LintRuleMissingTests, LintSeverityWarning, "template has no tests, add a scaffold_test.go")
+	}
+
+	category := path.Dir(templateDir)
+	if category == "." {
+		category = ""
+	}
+
+	return &lintedTemplate{dir: templateDir, category: category, name: templateFile.Name}, t.issues
+}
+
+// yamlErrorLine matches the errors of the yaml decoder, i.e. line 3: field foo not found in type templates.TemplateFile
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// lintTemplateFile reads scaffold.yaml strictly, and validates its fields. It returns false if the file can't be read at all
+func (t *templateLinter) lintTemplateFile() (TemplateFile, bool) {
+	content, err := os.ReadFile(filepath.Join(t.path, TemplateFileName))
+	if err != nil {
+		t.report(TemplateFileName, 0, LintRuleInvalidYAML, LintSeverityError, "failed to read: %s", err.Error())
+		return TemplateFile{}, false
+	}
+
+	var templateFile TemplateFile
+	if err := yaml.Unmarshal(content, &templateFile); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			line, message := yamlErrorLocation(err.Error())
+			t.report(TemplateFileName, line, LintRuleInvalidYAML, LintSeverityError, "%s", message)
+			return TemplateFile{}, false
+		}
+
+		for _, typeErr := range typeErr.Errors {
+			line, message := yamlErrorLocation(typeErr)
+			t.report(TemplateFileName, line, LintRuleInvalidField, LintSeverityError, "%s", message)
+		}
+	}
+
+	// Unknown keys are ignored when scaffolding, so they are most likely a typo
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	var strictTemplateFile TemplateFile
+	if err := decoder.Decode(&strictTemplateFile); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			for _, typeErr := range typeErr.Errors {
+				if !strings.Contains(typeErr, "not found in type") {
+					continue
+				}
+
+				line, message := yamlErrorLocation(typeErr)
+				t.report(TemplateFileName, line, LintRuleUnknownKey, LintSeverityError, "%s", message)
+			}
+		}
+	}
+
+	if templateFile.Name == "" {
+		t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "name is required")
+	} else if strings.Contains(templateFile.Name, "/") {
+		t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "name: %s can't contain /, use folders to put the template in a category", templateFile.Name)
+	}
+
+	if templateFile.Deprecated && templateFile.Replacement == "" {
+		t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityWarning, "template is deprecated without a replacement")
+	}
+
+	if templateFile.MinScaffoldVersion != "" {
+		if !validVersion(templateFile.MinScaffoldVersion) {
+			t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "invalid minScaffoldVersion: %s, expected a version like 1.2.0", templateFile.MinScaffoldVersion)
+		}
+	}
+
+	for _, relPath := range slices.Sorted(maps.Keys(templateFile.Files)) {
+		fileConfig := templateFile.Files[relPath]
+
+		writeMode, err := ParseWriteMode(fileConfig.Mode)
+		if err != nil {
+			t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "files.%s.mode: %s", relPath, err.Error())
+		}
+
+		if _, err := ParseInjectPosition(string(fileConfig.Position)); err != nil {
+			t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "files.%s.position: %s", relPath, err.Error())
+		}
+
+		switch writeMode {
+		case TemplatedFileWriteModeInject:
+			if fileConfig.Anchor == "" {
+				t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "files.%s is injected, but has no anchor", relPath)
+			}
+		case TemplatedFileWriteModeGoFunc, TemplatedFileWriteModeGoStruct, TemplatedFileWriteModeGoInterface:
+			if fileConfig.Target == "" {
+				t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "files.%s is inserted into go code, but has no target", relPath)
+			}
+		}
+
+		for _, postProcessor := range fileConfig.PostProcess {
+			if !t.postProcessors.Has(postProcessor) {
+				t.report(TemplateFileName, 0, LintRuleInvalidField, LintSeverityError, "files.%s.postProcess: unknown post processor: %s", relPath, postProcessor)
+			}
+		}
+	}
+
+	return templateFile, true
+}
+
+func yamlErrorLocation(message string) (int, string) {
+	matches := yamlErrorLine.FindStringSubmatch(message)
+	if matches == nil {
+		return 0, message
+	}
+
+	line, _ := strconv.Atoi(matches[1])
+
+	return line, matches[2]
+}
+
+// lintFiles reads the files of the template, and checks that the files configured in scaffold.yaml exist, either in the template or in the template it extends. It returns false if the files can't be read
+func (t *templateLinter) lintFiles(templateFile TemplateFile, inheritedFiles map[string]bool) ([]File, bool) {
+	filesPath := filepath.Join(t.path, "files")
+	if _, err := os.Stat(filesPath); err != nil {
+		// A template extending another may only change its scaffold.yaml
+		if templateFile.Extends == "" {
+			t.report("files", 0, LintRuleMissingFiles, LintSeverityError, "template has no files folder")
+		}
+
+		for _, relPath := range slices.Sorted(maps.Keys(templateFile.Files)) {
+			if !inheritedFiles[relPath] {
+				t.report(TemplateFileName, 0, LintRuleUnmatchedFile, LintSeverityError, "files.%s doesn't match any file in files/", relPath)
+			}
+		}
+
+		return nil, false
+	}
+
+	files := make([]File, 0)
+	err := filepath.WalkDir(filesPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(filesPath, filePath)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, File{content: content, path: filePath, RelPath: filepath.ToSlash(relPath)})
+
+		return nil
+	})
+	if err != nil {
+		t.report("files", 0, LintRuleMissingFiles, LintSeverityError, "failed to read files: %s", err.Error())
+		return nil, false
+	}
+
+	configuredFiles := maps.Clone(inheritedFiles)
+	for _, file := range files {
+		configuredFiles[strings.TrimSuffix(file.RelPath, ".gotmpl")] = true
+	}
+
+	for _, relPath := range slices.Sorted(maps.Keys(templateFile.Files)) {
+		if !configuredFiles[relPath] {
+			t.report(TemplateFileName, 0, LintRuleUnmatchedFile, LintSeverityError, "files.%s doesn't match any file in files/", relPath)
+		}
+	}
+
+	return files, true
+}
+
+// templateErrorLine matches the errors of text/template, i.e. template: main.go:3: function "Foo" not defined
+var templateErrorLine = regexp.MustCompile(`^template: [^:]*:(\d+):(?:\d+:)? ?(.*)$`)
+
+// lintGoTemplate parses content as a go template, like the loader would, and checks that it only refers to declared inputs. The field is where in scaffold.yaml the template is, if it isn't a file
+func (t *templateLinter) lintGoTemplate(file string, field string, content string, declaredInputs map[string]bool) {
+	prefix := ""
+	if field != "" {
+		prefix = field + ": "
+	}
+
+	tmpl, err := gotmpl.New(file).Funcs(funcs).Funcs(writeModeFuncs(&fileOptions{})).Parse(content)
+	if err != nil {
+		line := 0
+		message := err.Error()
+		if matches := templateErrorLine.FindStringSubmatch(message); matches != nil && field == "" {
+			line, _ = strconv.Atoi(matches[1])
+			message = matches[2]
+		}
+
+		t.report(file, line, LintRuleParseError, LintSeverityError, "%s%s", prefix, message)
+		return
+	}
+
+	for _, definedTmpl := range tmpl.Templates() {
+		if definedTmpl.Tree == nil {
+			continue
+		}
+
+		inputReferences(definedTmpl.Tree.Root, true, func(name string, node parse.Node) {
+			if declaredInputs[name] {
+				return
+			}
+
+			line := 0
+			if field == "" {
+				line = nodeLine(definedTmpl.Tree, node)
+			}
+
+			t.report(file, line, LintRuleUndeclaredInput, LintSeverityError, "%sinput: %s is used, but isn't declared in scaffold.yaml", prefix, name)
+		})
+	}
+}
+
+// inputReferences calls ref for every input the template refers to, through .Input.name, $.Input.name or index .Input "name". The dot is only the template outside of range and with
+func inputReferences(node parse.Node, dotIsTemplate bool, ref func(name string, node parse.Node)) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+
+		for _, child := range node.Nodes {
+			inputReferences(child, dotIsTemplate, ref)
+		}
+	case *parse.ActionNode:
+		inputReferences(node.Pipe, dotIsTemplate, ref)
+	case *parse.TemplateNode:
+		inputReferences(node.Pipe, dotIsTemplate, ref)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+
+		for _, cmd := range node.Cmds {
+			inputReferences(cmd, dotIsTemplate, ref)
+		}
+	case *parse.CommandNode:
+		if len(node.Args) >= 3 {
+			if identifier, ok := node.Args[0].(*parse.IdentifierNode); ok && identifier.Ident == "index" && isInputs(node.Args[1], dotIsTemplate) {
+				if name, ok := node.Args[2].(*parse.StringNode); ok {
+					ref(name.Text, node)
+				}
+			}
+		}
+
+		for _, arg := range node.Args {
+			inputReferences(arg, dotIsTemplate, ref)
+		}
+	case *parse.FieldNode:
+		if dotIsTemplate && len(node.Ident) >= 2 && node.Ident[0] == "Input" {
+			ref(node.Ident[1], node)
+		}
+	case *parse.VariableNode:
+		if len(node.Ident) >= 3 && node.Ident[0] == "$" && node.Ident[1] == "Input" {
+			ref(node.Ident[2], node)
+		}
+	case *parse.IfNode:
+		inputReferences(node.Pipe, dotIsTemplate, ref)
+		inputReferences(node.List, dotIsTemplate, ref)
+		inputReferences(node.ElseList, dotIsTemplate, ref)
+	case *parse.RangeNode:
+		inputReferences(node.Pipe, dotIsTemplate, ref)
+		inputReferences(node.List, false, ref)
+		inputReferences(node.ElseList, dotIsTemplate, ref)
+	case *parse.WithNode:
+		inputReferences(node.Pipe, dotIsTemplate, ref)
+		inputReferences(node.List, false, ref)
+		inputReferences(node.ElseList, dotIsTemplate, ref)
+	}
+}
+
+// isInputs returns whether the node is the inputs of the template, .Input or $.Input
+func isInputs(node parse.Node, dotIsTemplate bool) bool {
+	switch node := node.(type) {
+	case *parse.FieldNode:
+		return dotIsTemplate && len(node.Ident) == 1 && node.Ident[0] == "Input"
+	case *parse.VariableNode:
+		return len(node.Ident) == 2 && node.Ident[0] == "$" && node.Ident[1] == "Input"
+	default:
+		return false
+	}
+}
+
+func nodeLine(tree *parse.Tree, node parse.Node) int {
+	location, _ := tree.ErrorContext(node)
+
+	// The location is name:line:column
+	parts := strings.Split(location, ":")
+	if len(parts) < 3 {
+		return 0
+	}
+
+	line, _ := strconv.Atoi(parts[len(parts)-2])
+
+	return line
+}
+
+// lintDuplicateNames reports templates with the same name in the same category, as only one of them can be used. The same name in different categories means the name can only be used with its category
+func lintDuplicateNames(lintedTemplates []*lintedTemplate) []LintIssue {
+	var (
+		issues     = make([]LintIssue, 0)
+		fullNames  = make(map[string]string)
+		plainNames = make(map[string]string)
+	)
+
+	for _, template := range lintedTemplates {
+		if template == nil || template.name == "" {
+			continue
+		}
+
+		fullName := path.Join(template.category, template.name)
+		if other, ok := fullNames[fullName]; ok {
+			issues = append(issues, LintIssue{
+				Template: template.dir,
+				File:     TemplateFileName,
+				Rule:     LintRuleDuplicateName,
+				Severity: LintSeverityError,
+				Message:  fmt.Sprintf("name: %s is already used by the template in: %s", template.name, other),
+			})
+			continue
+		}
+		fullNames[fullName] = template.dir
+
+		if other, ok := plainNames[template.name]; ok {
+			issues = append(issues, LintIssue{
+				Template: template.dir,
+				File:     TemplateFileName,
+				Rule:     LintRuleDuplicateName,
+				Severity: LintSeverityWarning,
+				Message:  fmt.Sprintf("name: %s is also used by the template in: %s, so both can only be used by their full name", template.name, other),
+			})
+			continue
+		}
+		plainNames[template.name] = template.dir
+	}
+
+	return issues
+}
@@ -0,0 +1,117 @@
+package templates
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestRegistry(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	registryPath := t.TempDir()
+	for filePath, content := range files {
+		require.NoError(t, os.MkdirAll(path.Dir(path.Join(registryPath, filePath)), 0o755))
+		require.NoError(t, os.WriteFile(path.Join(registryPath, filePath), []byte(content), 0o644))
+	}
+
+	return registryPath
+}
+
+func TestLinter(t *testing.T) {
+	registryPath := writeTestRegistry(t, map[string]string{
+		"service/scaffold.yaml": `name: service
+default:
+  path: "{{ .Input.name }}"
+input:
+  name:
+    type: string
+files:
+  main.go:
+    rename: "cmd/{{ .Input.binary }}/main.go"
+  missing.go:
+    mode: inject
+  other.go:
+    mode: overwrite
+    postProcess: [gofmt, prettier]
+descripton: typo
+`,
+		"service/scaffold_test.go": "package service\n",
+		"service/files/main.go.gotmpl": `package main
+
+// {{ .Input.name }} {{ index .Input "port" }}
+{{ range .Items }}{{ .Input.ignored }}{{ $.Input.host }}{{ end }}
+`,
+		"service/files/broken.go.gotmpl": "package main\n\n{{ .Input.name \n",
+		"go/service/scaffold.yaml":       "name: service\n",
+		"go/service/files/main.go":       "package main\n",
+		"go/other/scaffold.yaml":         "name: service\n",
+		"go/other/files/main.go":         "package main\n",
+		"invalid/scaffold.yaml":          "name: [invalid\n",
+	})
+
+	issues, err := NewLinter().Lint(t.Context(), registryPath, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	require.NoError(t, err)
+
+	actual := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		actual = append(actual, issue.String())
+	}
+
+	assert.Equal(t, []string{
+		"go/other/scaffold_test.go: warning: template has no tests, add a scaffold_test.go (missing-tests)",
+		"go/service/scaffold.yaml: error: name: service is already used by the template in: go/other (duplicate-name)",
+		"go/service/scaffold_test.go: warning: template has no tests, add a scaffold_test.go (missing-tests)",
+		"invalid/scaffold.yaml:1: error: did not find expected ',' or ']' (invalid-yaml)",
+		"service/files/broken.go.gotmpl:4: error: unclosed action started at files/broken.go.gotmpl:3 (parse-error)",
+		"service/files/main.go.gotmpl:3: error: input: port is used, but isn't declared in scaffold.yaml (undeclared-input)",
+		"service/files/main.go.gotmpl:4: error: input: host is used, but isn't declared in scaffold.yaml (undeclared-input)",
+		"service/scaffold.yaml: error: files.missing.go is injected, but has no anchor (invalid-field)",
+		"service/scaffold.yaml: error: files.other.go.mode: unknown write mode: 'overwrite', must be one of: append, go-func, go-import, go-interface, go-struct, inject, merge-json, merge-yaml, prepend, skip-if-exists, write (invalid-field)",
+		"service/scaffold.yaml: error: files.other.go.postProcess: unknown post processor: prettier (invalid-field)",
+		"service/scaffold.yaml: error: files.missing.go doesn't match any file in files/ (unmatched-file)",
+		"service/scaffold.yaml: error: files.other.go doesn't match any file in files/ (unmatched-file)",
+		"service/scaffold.yaml: error: files.main.go.rename: input: binary is used, but isn't declared in scaffold.yaml (undeclared-input)",
+		"service/scaffold.yaml: warning: name: service is also used by the template in: go/other, so both can only be used by their full name (duplicate-name)",
+		"service/scaffold.yaml:15: error: field descripton not found in type templates.TemplateFile (unknown-key)",
+	}, actual)
+
+	t.Run("lints a single template", func(t *testing.T) {
+		issues, err := NewLinter().Lint(t.Context(), path.Join(registryPath, "go", "service"), slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, "service", issues[0].Template)
+		assert.Equal(t, LintRuleMissingTests, issues[0].Rule)
+	})
+}
+
+func TestLinterExtends(t *testing.T) {
+	registryPath := writeTestRegistry(t, map[string]string{
+		"service/scaffold.yaml":    "name: service\ninput:\n  name:\n    type: string\n",
+		"service/scaffold_test.go": "package service\n",
+		"service/files/main.go":    "package main\n",
+		"grpc/scaffold.yaml":       "name: grpc\nextends: service\nfiles:\n  main.go:\n    rename: \"cmd/{{ .Input.name }}/main.go\"\n  api.proto:\n    rename: \"{{ .Input.proto }}.proto\"\n",
+		"grpc/scaffold_test.go":    "package grpc\n",
+		"missing/scaffold.yaml":    "name: missing\nextends: unknown\n",
+		"missing/scaffold_test.go": "package missing\n",
+	})
+
+	issues, err := NewLinter().Lint(t.Context(), registryPath, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	require.NoError(t, err)
+
+	actual := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		actual = append(actual, issue.String())
+	}
+
+	assert.Equal(t, []string{
+		"grpc/scaffold.yaml: error: files.api.proto doesn't match any file in files/ (unmatched-file)",
+		"grpc/scaffold.yaml: error: files.api.proto.rename: input: proto is used, but isn't declared in scaffold.yaml (undeclared-input)",
+		"missing/scaffold.yaml: error: extends: template: unknown not found (unresolved-template)",
+	}, actual)
+}
@@ -3,14 +3,15 @@ package templates
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
-	"sync"
 	gotmpl "text/template"
 
 	"github.com/iancoleman/strcase"
@@ -19,11 +20,22 @@ import (
 
 // TemplateLoader reads a templates files and runs their respective templating on them.
 type TemplateLoader struct {
-	logger *slog.Logger
+	logger         *slog.Logger
+	postProcessors *PostProcessors
 }
 
 func NewTemplateLoader(logger *slog.Logger) *TemplateLoader {
-	return &TemplateLoader{logger}
+	return &TemplateLoader{
+		logger:         logger,
+		postProcessors: DefaultPostProcessors(),
+	}
+}
+
+// WithPostProcessors replaces the post processors run over the templated files
+func (t *TemplateLoader) WithPostProcessors(postProcessors *PostProcessors) *TemplateLoader {
+	t.postProcessors = postProcessors
+
+	return t
 }
 
 type File struct {
@@ -46,23 +58,50 @@ var funcs = gotmpl.FuncMap{
 
 // TemplatePath formats the template file path using go templates, this is useful for programmatically changing the output string using go tmpls
 func TemplatePath(template *Template) (string, error) {
-	tmpl, err := gotmpl.New("path").Funcs(funcs).Parse(template.File.Default.Path)
+	return renderString("path", template.File.Default.Path, template)
+}
+
+// Load loads the template files from disk. The files of a template extending another are added to the files of its base, replacing the ones with the same path
+func (t *TemplateLoader) Load(ctx context.Context, template *Template) ([]File, error) {
+	if template.Base == nil {
+		return t.loadFiles(ctx, template)
+	}
+
+	baseFiles, err := t.Load(ctx, template.Base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load files of extended template: %s, %w", template.Base.FullName(), err)
+	}
+
+	// A template extending another may only change its scaffold.yaml, and have no files of its own
+	if _, err := os.Stat(path.Join(template.Path, "files")); errors.Is(err, os.ErrNotExist) {
+		return baseFiles, nil
+	}
+
+	files, err := t.loadFiles(ctx, template)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// main.go and main.go.gotmpl are the same file once templated
+	overridden := make(map[string]bool, len(files))
+	for _, file := range files {
+		overridden[strings.TrimSuffix(file.RelPath, ".gotmpl")] = true
 	}
 
-	output := bytes.NewBufferString("")
-	if err := tmpl.Execute(output, template); err != nil {
-		return "", err
+	for _, baseFile := range baseFiles {
+		if !overridden[strings.TrimSuffix(baseFile.RelPath, ".gotmpl")] {
+			files = append(files, baseFile)
+		}
 	}
 
-	templatePath := strings.TrimSpace(output.String())
+	slices.SortStableFunc(files, func(a, b File) int {
+		return strings.Compare(a.RelPath, b.RelPath)
+	})
 
-	return templatePath, nil
+	return files, nil
 }
 
-// Load loads the template files from disk
-func (t *TemplateLoader) Load(ctx context.Context, template *Template) ([]File, error) {
+func (t *TemplateLoader) loadFiles(ctx context.Context, template *Template) ([]File, error) {
 	templateFilePath := path.Join(template.Path, "files")
 	if _, err := os.Stat(templateFilePath); err != nil {
 		return nil, fmt.Errorf("failed to lookup template files %s, %w", templateFilePath, err)
@@ -85,26 +124,21 @@ func (t *TemplateLoader) Load(ctx context.Context, template *Template) ([]File,
 		return nil, fmt.Errorf("failed to read template files: %w", err)
 	}
 
-	var (
-		filesLock sync.Mutex
-		files     = make([]File, 0)
-	)
+	// The files keep the lexical order of the walk, so files written to the same destination are applied in the same order on every run
+	files := make([]File, len(filePaths))
 	egrp, _ := errgroup.WithContext(ctx)
-	for _, filePath := range filePaths {
+	for i, filePath := range filePaths {
 		egrp.Go(func() error {
 			fileContent, err := os.ReadFile(filePath)
 			if err != nil {
 				return fmt.Errorf("failed to read file: %s, %w", filePath, err)
 			}
 
-			filesLock.Lock()
-			defer filesLock.Unlock()
-
-			files = append(files, File{
+			files[i] = File{
 				content: fileContent,
 				path:    filePath,
 				RelPath: strings.TrimPrefix(strings.TrimPrefix(filePath, templateFilePath), "/"),
-			})
+			}
 
 			return nil
 		})
@@ -121,35 +155,120 @@ type TemplatedFile struct {
 	Content         []byte
 	DestinationPath string
 	Mode            TemplatedFileWriteMode
+	// Template is the full name of the template the file comes from, as several templates are written together when a template uses others
+	Template string
+
+	// Anchor and Position tells where to put the content when injecting into an existing file
+	Anchor   string
+	Position InjectPosition
+	// Target is the go function, struct or interface to add the content to for the go write modes
+	Target string
+}
+
+// Describe returns how the file is written, i.e. inject after // scaffold:routes
+func (t TemplatedFile) Describe() string {
+	switch t.Mode {
+	case TemplatedFileWriteModeInject:
+		position := t.Position
+		if position == "" {
+			position = InjectPositionAfter
+		}
+
+		return fmt.Sprintf("%s %s %s", t.Mode, position, t.Anchor)
+	case TemplatedFileWriteModeGoFunc, TemplatedFileWriteModeGoStruct, TemplatedFileWriteModeGoInterface:
+		return fmt.Sprintf("%s %s", t.Mode, t.Target)
+	default:
+		return t.Mode.String()
+	}
 }
 
 type TemplatedFileWriteMode string
 
+// String returns the name of the write mode as used in scaffold.yaml
+func (m TemplatedFileWriteMode) String() string {
+	for name, mode := range writeModes {
+		if mode == m {
+			return name
+		}
+	}
+
+	return string(m)
+}
+
 const (
-	TemplatedFileWriteModeFile   = "WRITE"
-	TemplatedFileWriteModeAppend = "APPEND"
+	TemplatedFileWriteModeFile         = "WRITE"
+	TemplatedFileWriteModeAppend       = "APPEND"
+	TemplatedFileWriteModePrepend      = "PREPEND"
+	TemplatedFileWriteModeSkipIfExists = "SKIP_IF_EXISTS"
+	TemplatedFileWriteModeInject       = "INJECT"
+
+	TemplatedFileWriteModeGoImport    = "GO_IMPORT"
+	TemplatedFileWriteModeGoFunc      = "GO_FUNC"
+	TemplatedFileWriteModeGoStruct    = "GO_STRUCT"
+	TemplatedFileWriteModeGoInterface = "GO_INTERFACE"
+
+	TemplatedFileWriteModeMergeYAML = "MERGE_YAML"
+	TemplatedFileWriteModeMergeJSON = "MERGE_JSON"
 )
 
+// writeModes are the names used for the write modes in scaffold.yaml
+var writeModes = map[string]TemplatedFileWriteMode{
+	"write":          TemplatedFileWriteModeFile,
+	"append":         TemplatedFileWriteModeAppend,
+	"prepend":        TemplatedFileWriteModePrepend,
+	"skip-if-exists": TemplatedFileWriteModeSkipIfExists,
+	"inject":         TemplatedFileWriteModeInject,
+	"merge-yaml":     TemplatedFileWriteModeMergeYAML,
+	"merge-json":     TemplatedFileWriteModeMergeJSON,
+	"go-import":      TemplatedFileWriteModeGoImport,
+	"go-func":        TemplatedFileWriteModeGoFunc,
+	"go-struct":      TemplatedFileWriteModeGoStruct,
+	"go-interface":   TemplatedFileWriteModeGoInterface,
+}
+
+// ParseWriteMode parses a write mode as given in scaffold.yaml, an empty mode is the default of writing the file
+func ParseWriteMode(mode string) (TemplatedFileWriteMode, error) {
+	if mode == "" {
+		return TemplatedFileWriteModeFile, nil
+	}
+
+	writeMode, ok := writeModes[mode]
+	if !ok {
+		names := make([]string, 0, len(writeModes))
+		for name := range writeModes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		return "", fmt.Errorf("unknown write mode: '%s', must be one of: %s", mode, strings.Join(names, ", "))
+	}
+
+	return writeMode, nil
+}
+
 // TemplateFiles runs the actual templating on the files, and tells it where to go. The writes doesn't happen here yet.
 func (l *TemplateLoader) TemplateFiles(template *Template, files []File, scaffoldDest string) ([]TemplatedFile, error) {
 	templatedFiles := make([]TemplatedFile, 0)
 	for _, file := range files {
-		var writeMode TemplatedFileWriteMode = TemplatedFileWriteModeFile
+		fileConfig := template.FileConfig(file.RelPath)
+
+		// The write mode in scaffold.yaml is the default, which the file itself can override
+		writeMode, err := ParseWriteMode(fileConfig.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode for: %s in scaffold.yaml: %w", file.RelPath, err)
+		}
+
+		options := fileOptions{
+			writeMode: writeMode,
+			anchor:    fileConfig.Anchor,
+			position:  fileConfig.Position,
+			target:    fileConfig.Target,
+		}
 
 		tmpl, err := gotmpl.
 			New(file.RelPath).
 			Funcs(funcs).
-			Funcs(gotmpl.FuncMap{
-				"WriteModeFile": func() string {
-					writeMode = TemplatedFileWriteModeFile
-					// Needs a single return value at least, empty string to not pollute output
-					return ""
-				},
-				"WriteModeAppend": func() string {
-					writeMode = TemplatedFileWriteModeAppend
-					return ""
-				},
-			}).
+			Funcs(writeModeFuncs(&options)).
 			Parse(string(file.content))
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse template file: %s, %w", file.RelPath, err)
@@ -166,7 +285,7 @@ func (l *TemplateLoader) TemplateFiles(template *Template, files []File, scaffol
 
 		//slog.Info("file renames", "renames", template.File.Files)
 
-		if fileConfig, ok := template.File.Files[strings.TrimSuffix(file.RelPath, ".gotmpl")]; ok && fileConfig.Rename != "" {
+		if fileConfig.Rename != "" {
 			l.logger.Debug("templating file", "path", file.RelPath, "rename", fileConfig.Rename)
 
 			renameTmpl, err := gotmpl.New(file.RelPath).Funcs(funcs).Parse(fileConfig.Rename)
@@ -194,13 +313,112 @@ func (l *TemplateLoader) TemplateFiles(template *Template, files []File, scaffol
 			l.logger.Debug("using raw file path", "path", file.RelPath)
 		}
 
+		// Only whole files are formatted by default, appended snippets are left as is, unless configured
+		postProcessors := fileConfig.PostProcess
+		if len(postProcessors) == 0 && (options.writeMode == TemplatedFileWriteModeFile || options.writeMode == TemplatedFileWriteModeSkipIfExists) {
+			postProcessors = l.postProcessors.Defaults(filePath)
+		}
+
+		switch options.writeMode {
+		case TemplatedFileWriteModeInject:
+			if options.anchor == "" {
+				return nil, fmt.Errorf("file: %s is injected, but has no anchor", file.RelPath)
+			}
+			if _, err := ParseInjectPosition(string(options.position)); err != nil {
+				return nil, fmt.Errorf("invalid position for: %s in scaffold.yaml: %w", file.RelPath, err)
+			}
+		case TemplatedFileWriteModeGoFunc, TemplatedFileWriteModeGoStruct, TemplatedFileWriteModeGoInterface:
+			if options.target == "" {
+				return nil, fmt.Errorf("file: %s is inserted into go code, but has no target", file.RelPath)
+			}
+		}
+
+		content, err := l.postProcessors.Process(filePath, postProcessors, output.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to post process file: %s, %w", file.RelPath, err)
+		}
+
 		templatedFiles = append(templatedFiles, TemplatedFile{
-			Content:         output.Bytes(),
+			Content:         content,
 			DestinationPath: path.Join(scaffoldDest, filePath),
+			Template:        template.FullName(),
 
-			Mode: writeMode,
+			Mode:     options.writeMode,
+			Anchor:   options.anchor,
+			Position: options.position,
+			Target:   options.target,
 		})
 	}
 
 	return templatedFiles, nil
 }
+
+// fileOptions are how a templated file is written, the file can set them from its template through the WriteMode functions
+type fileOptions struct {
+	writeMode TemplatedFileWriteMode
+	anchor    string
+	position  InjectPosition
+	target    string
+}
+
+// writeModeFuncs are the functions a template file can call to choose how it is written, overriding its config in scaffold.yaml
+func writeModeFuncs(options *fileOptions) gotmpl.FuncMap {
+	return gotmpl.FuncMap{
+		"WriteModeFile": func() string {
+			options.writeMode = TemplatedFileWriteModeFile
+			// Needs a single return value at least, empty string to not pollute output
+			return ""
+		},
+		"WriteModeAppend": func() string {
+			options.writeMode = TemplatedFileWriteModeAppend
+			return ""
+		},
+		"WriteModePrepend": func() string {
+			options.writeMode = TemplatedFileWriteModePrepend
+			return ""
+		},
+		"WriteModeSkipIfExists": func() string {
+			options.writeMode = TemplatedFileWriteModeSkipIfExists
+			return ""
+		},
+		"WriteModeInjectBefore": func(injectAnchor string) string {
+			options.writeMode = TemplatedFileWriteModeInject
+			options.anchor = injectAnchor
+			options.position = InjectPositionBefore
+			return ""
+		},
+		"WriteModeInjectAfter": func(injectAnchor string) string {
+			options.writeMode = TemplatedFileWriteModeInject
+			options.anchor = injectAnchor
+			options.position = InjectPositionAfter
+			return ""
+		},
+		"WriteModeMergeYAML": func() string {
+			options.writeMode = TemplatedFileWriteModeMergeYAML
+			return ""
+		},
+		"WriteModeMergeJSON": func() string {
+			options.writeMode = TemplatedFileWriteModeMergeJSON
+			return ""
+		},
+		"WriteModeGoImport": func() string {
+			options.writeMode = TemplatedFileWriteModeGoImport
+			return ""
+		},
+		"WriteModeGoFunc": func(funcName string) string {
+			options.writeMode = TemplatedFileWriteModeGoFunc
+			options.target = funcName
+			return ""
+		},
+		"WriteModeGoStruct": func(structName string) string {
+			options.writeMode = TemplatedFileWriteModeGoStruct
+			options.target = structName
+			return ""
+		},
+		"WriteModeGoInterface": func(interfaceName string) string {
+			options.writeMode = TemplatedFileWriteModeGoInterface
+			options.target = interfaceName
+			return ""
+		},
+	}
+}
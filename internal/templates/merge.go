@@ -0,0 +1,391 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergeYAML deep merges the content documents into the existing documents, keeping the existing key order and comments. A document with a kind and metadata.name, i.e. a k8s manifest, is merged into the existing document with the same kind and name, or added if there is none. Other documents are merged into the existing document at the same position
+func mergeYAML(existing, content []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return content, nil
+	}
+
+	existingDocuments, err := decodeYAMLDocuments(existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing yaml: %w", err)
+	}
+
+	contentDocuments, err := decodeYAMLDocuments(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templated yaml: %w", err)
+	}
+
+	if len(contentDocuments) == 0 {
+		return existing, nil
+	}
+
+	if len(existingDocuments) == 0 {
+		return content, nil
+	}
+
+	// Re-encoding loses blank lines and the quoting style, so the file is only re-encoded when something is added to it
+	missing := false
+	for i, contentDocument := range contentDocuments {
+		existingDocument := findYAMLDocument(existingDocuments, i, contentDocument)
+		if existingDocument == nil || !containsNode(existingDocument.Content[0], contentDocument.Content[0]) {
+			missing = true
+			break
+		}
+	}
+
+	if !missing {
+		return existing, nil
+	}
+
+	for i, contentDocument := range contentDocuments {
+		existingDocument := findYAMLDocument(existingDocuments, i, contentDocument)
+		if existingDocument == nil {
+			existingDocuments = append(existingDocuments, contentDocument)
+			continue
+		}
+
+		mergeNodes(existingDocument.Content[0], contentDocument.Content[0])
+	}
+
+	output := bytes.NewBufferString("")
+	encoder := yaml.NewEncoder(output)
+	encoder.SetIndent(2)
+	for _, existingDocument := range existingDocuments {
+		if err := encoder.Encode(existingDocument); err != nil {
+			return nil, fmt.Errorf("failed to encode merged yaml: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode merged yaml: %w", err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// decodeYAMLDocuments decodes every document of a multi document yaml file, empty documents are left out
+func decodeYAMLDocuments(content []byte) ([]*yaml.Node, error) {
+	documents := make([]*yaml.Node, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				return documents, nil
+			}
+
+			return nil, err
+		}
+
+		if len(document.Content) > 0 {
+			documents = append(documents, &document)
+		}
+	}
+}
+
+// findYAMLDocument finds the existing document the content document at index is merged into, nil if it is a new document
+func findYAMLDocument(existingDocuments []*yaml.Node, index int, contentDocument *yaml.Node) *yaml.Node {
+	if id := yamlDocumentID(contentDocument); id != "" {
+		for _, existingDocument := range existingDocuments {
+			if yamlDocumentID(existingDocument) == id {
+				return existingDocument
+			}
+		}
+
+		return nil
+	}
+
+	if index < len(existingDocuments) {
+		return existingDocuments[index]
+	}
+
+	return nil
+}
+
+// yamlDocumentID identifies a k8s style document by its kind and metadata.name, empty if it has neither
+func yamlDocumentID(document *yaml.Node) string {
+	kind := yamlMappingValue(document.Content[0], "kind")
+	metadata := yamlMappingValue(document.Content[0], "metadata")
+	if kind == nil || metadata == nil {
+		return ""
+	}
+
+	name := yamlMappingValue(metadata, "name")
+	if name == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s", kind.Value, name.Value)
+}
+
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// mergeJSON deep merges the content document into the existing document, keeping the existing key order
+func mergeJSON(existing, content []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return content, nil
+	}
+
+	existingDocument, err := decodeJSON(existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing json: %w", err)
+	}
+
+	contentDocument, err := decodeJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templated json: %w", err)
+	}
+
+	if containsNode(existingDocument, contentDocument) {
+		return existing, nil
+	}
+
+	mergeNodes(existingDocument, contentDocument)
+
+	output := bytes.NewBufferString("")
+	encodeJSON(output, existingDocument, "")
+	output.WriteString("\n")
+
+	return output.Bytes(), nil
+}
+
+// mergeNodes merges src into dst. Mappings are merged key by key, new sequence items are appended, and scalars are replaced
+func mergeNodes(dst, src *yaml.Node) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			srcKey, srcValue := src.Content[i], src.Content[i+1]
+
+			found := false
+			for j := 0; j+1 < len(dst.Content); j += 2 {
+				if dst.Content[j].Value == srcKey.Value {
+					mergeNodes(dst.Content[j+1], srcValue)
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				dst.Content = append(dst.Content, srcKey, srcValue)
+			}
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		for _, srcItem := range src.Content {
+			found := false
+			for _, dstItem := range dst.Content {
+				if nodesEqual(dstItem, srcItem) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				dst.Content = append(dst.Content, srcItem)
+			}
+		}
+	default:
+		headComment, lineComment, footComment := dst.HeadComment, dst.LineComment, dst.FootComment
+		*dst = *src
+		if dst.HeadComment == "" {
+			dst.HeadComment = headComment
+		}
+		if dst.LineComment == "" {
+			dst.LineComment = lineComment
+		}
+		if dst.FootComment == "" {
+			dst.FootComment = footComment
+		}
+	}
+}
+
+// containsNode tells whether merging src into dst would leave dst as it is
+func containsNode(dst, src *yaml.Node) bool {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			dstValue := yamlMappingValue(dst, src.Content[i].Value)
+			if dstValue == nil || !containsNode(dstValue, src.Content[i+1]) {
+				return false
+			}
+		}
+
+		return true
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		for _, srcItem := range src.Content {
+			if !slices.ContainsFunc(dst.Content, func(dstItem *yaml.Node) bool { return nodesEqual(dstItem, srcItem) }) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return nodesEqual(dst, src)
+	}
+}
+
+func nodesEqual(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+
+	for i := range a.Content {
+		if !nodesEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decodeJSON reads a json document into a yaml node, as opposed to a map it keeps the order of the keys
+func decodeJSON(content []byte) (*yaml.Node, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	node, err := decodeJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected content after json document")
+	}
+
+	return node, nil
+}
+
+func decodeJSONValue(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if value == '[' {
+			node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+
+		for decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(key)})
+			}
+
+			item, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			node.Content = append(node.Content, item)
+		}
+
+		// Consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+
+		return node, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+	case json.Number:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: value.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(value)}, nil
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected json token: %v", token)
+}
+
+func encodeJSON(output *bytes.Buffer, node *yaml.Node, indent string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			encodeJSON(output, node.Content[0], indent)
+		}
+	case yaml.AliasNode:
+		encodeJSON(output, node.Alias, indent)
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			output.WriteString("{}")
+			return
+		}
+
+		output.WriteString("{\n")
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			output.WriteString(indent + "  ")
+			encodeJSONString(output, node.Content[i].Value)
+			output.WriteString(": ")
+			encodeJSON(output, node.Content[i+1], indent+"  ")
+			if i+2 < len(node.Content) {
+				output.WriteString(",")
+			}
+			output.WriteString("\n")
+		}
+		output.WriteString(indent + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			output.WriteString("[]")
+			return
+		}
+
+		output.WriteString("[\n")
+		for i, item := range node.Content {
+			output.WriteString(indent + "  ")
+			encodeJSON(output, item, indent+"  ")
+			if i+1 < len(node.Content) {
+				output.WriteString(",")
+			}
+			output.WriteString("\n")
+		}
+		output.WriteString(indent + "]")
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!float", "!!int", "!!bool", "!!null":
+			output.WriteString(strings.TrimSpace(node.Value))
+		default:
+			encodeJSONString(output, node.Value)
+		}
+	}
+}
+
+func encodeJSONString(output *bytes.Buffer, value string) {
+	content := bytes.NewBufferString("")
+	encoder := json.NewEncoder(content)
+	encoder.SetEscapeHTML(false)
+	// Encoding a string can't fail
+	_ = encoder.Encode(value)
+
+	output.Write(bytes.TrimSuffix(content.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,185 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeYAML(t *testing.T) {
+	existing := `# services used for local development
+services:
+  postgres:
+    image: postgres:16 # pinned
+    ports:
+      - 5432:5432
+`
+	content := `services:
+  postgres:
+    ports:
+      - 5432:5432
+      - 5433:5433
+  api:
+    image: api:latest
+`
+
+	output, err := mergeYAML([]byte(existing), []byte(content))
+	require.NoError(t, err)
+
+	assert.Equal(t, `# services used for local development
+services:
+  postgres:
+    image: postgres:16 # pinned
+    ports:
+      - 5432:5432
+      - 5433:5433
+  api:
+    image: api:latest
+`, string(output))
+
+	again, err := mergeYAML(output, []byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, string(output), string(again))
+}
+
+func TestMergeYAMLUnchanged(t *testing.T) {
+	existing := `# services used for local development
+services:
+
+  postgres:
+    image: "postgres:16"
+    ports: [5432:5432]
+
+---
+other: 'value'
+`
+
+	output, err := mergeYAML([]byte(existing), []byte("services:\n  postgres:\n    ports:\n      - 5432:5432\n"))
+	require.NoError(t, err)
+	assert.Equal(t, existing, string(output), "a merge which adds nothing leaves the file as it is")
+
+	merged, err := mergeYAML([]byte(existing), []byte("services:\n  api:\n    image: api\n"))
+	require.NoError(t, err)
+	assert.NotEqual(t, existing, string(merged))
+
+	again, err := mergeYAML(merged, []byte("services:\n  api:\n    image: api\n"))
+	require.NoError(t, err)
+	assert.Equal(t, string(merged), string(again), "a second apply is a no op")
+
+	json := "{\n    \"scripts\": {\"build\": \"go build\"}\n}\n"
+	output, err = mergeJSON([]byte(json), []byte(`{"scripts": {"build": "go build"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, json, string(output))
+}
+
+func TestMergeYAMLDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		content  string
+		expected string
+	}{
+		{
+			name:     "documents are merged by position",
+			existing: "a: 1\n---\nb: 2\n",
+			content:  "c: 3\n",
+			expected: "a: 1\nc: 3\n---\nb: 2\n",
+		},
+		{
+			name:     "extra documents are added",
+			existing: "a: 1\n",
+			content:  "a: 2\n---\nb: 2\n",
+			expected: "a: 2\n---\nb: 2\n",
+		},
+		{
+			name: "manifests are merged by kind and name",
+			existing: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  ports:
+    - port: 80
+`,
+			content: `kind: Service
+metadata:
+  name: api
+spec:
+  ports:
+    - port: 443
+---
+kind: Service
+metadata:
+  name: worker
+`,
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  ports:
+    - port: 80
+    - port: 443
+---
+kind: Service
+metadata:
+  name: worker
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := mergeYAML([]byte(test.existing), []byte(test.content))
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(output))
+
+			again, err := mergeYAML(output, []byte(test.content))
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(again))
+		})
+	}
+}
+
+func TestMergeJSON(t *testing.T) {
+	existing := `{
+	"name": "web",
+	"scripts": {"build": "vite build", "test": "vitest"},
+	"private": true,
+	"version": 1.5
+}`
+	content := `{"scripts": {"lint": "eslint . && prettier --check ."}, "private": false}`
+
+	output, err := mergeJSON([]byte(existing), []byte(content))
+	require.NoError(t, err)
+
+	assert.Equal(t, `{
+  "name": "web",
+  "scripts": {
+    "build": "vite build",
+    "test": "vitest",
+    "lint": "eslint . && prettier --check ."
+  },
+  "private": false,
+  "version": 1.5
+}
+`, string(output))
+
+	_, err = mergeJSON([]byte(existing), []byte(`{"scripts": `))
+	assert.Error(t, err)
+}
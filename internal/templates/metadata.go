@@ -0,0 +1,69 @@
+package templates
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// CheckUsable warns when the template, or a template it uses, is deprecated, and refuses the template if any of them requires a newer scaffold than scaffoldVersion. Development builds without a release version can use all templates
+func (t *Template) CheckUsable(ui *slog.Logger, scaffoldVersion string) error {
+	if err := t.checkUsable(ui, scaffoldVersion); err != nil {
+		return err
+	}
+
+	for _, dependency := range t.Dependencies {
+		if err := dependency.CheckUsable(ui, scaffoldVersion); err != nil {
+			return fmt.Errorf("used template: %s, %w", dependency.FullName(), err)
+		}
+	}
+
+	return nil
+}
+
+func (t *Template) checkUsable(ui *slog.Logger, scaffoldVersion string) error {
+	if t.File.Deprecated {
+		if t.File.Replacement != "" {
+			ui.Warn("template is deprecated, use its replacement instead", "template", t.FullName(), "replacement", t.File.Replacement)
+		} else {
+			ui.Warn("template is deprecated", "template", t.FullName())
+		}
+	}
+
+	if t.File.MinScaffoldVersion == "" {
+		return nil
+	}
+
+	minVersion := canonicalVersion(t.File.MinScaffoldVersion)
+	if !validVersion(t.File.MinScaffoldVersion) {
+		return fmt.Errorf("template: %s has an invalid minScaffoldVersion: %s, expected a version like 1.2.0", t.FullName(), t.File.MinScaffoldVersion)
+	}
+
+	currentVersion := canonicalVersion(scaffoldVersion)
+	if !validVersion(scaffoldVersion) {
+		ui.Debug("scaffold has no release version, skipping the version check of the template", "template", t.FullName(), "version", scaffoldVersion)
+		return nil
+	}
+
+	if semver.Compare(currentVersion, minVersion) < 0 {
+		return fmt.Errorf("template: %s requires scaffold %s or newer, but this is scaffold %s, please upgrade scaffold", t.FullName(), t.File.MinScaffoldVersion, scaffoldVersion)
+	}
+
+	return nil
+}
+
+// canonicalVersion adds the v prefix semver expects, so both 1.2.0 and v1.2.0 can be used
+func canonicalVersion(version string) string {
+	if version == "" || strings.HasPrefix(version, "v") {
+		return version
+	}
+
+	return "v" + version
+}
+
+// validVersion returns whether the version is a semantic version, with or without the v prefix
+func validVersion(version string) bool {
+	return semver.IsValid(canonicalVersion(version))
+}
@@ -0,0 +1,67 @@
+package templates
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateCheckUsable(t *testing.T) {
+	tests := []struct {
+		name               string
+		minScaffoldVersion string
+		scaffoldVersion    string
+		expectedErr        string
+	}{
+		{name: "no minimum version", scaffoldVersion: "0.1.0"},
+		{name: "same version", minScaffoldVersion: "1.2.0", scaffoldVersion: "1.2.0"},
+		{name: "newer scaffold", minScaffoldVersion: "1.2.0", scaffoldVersion: "v1.10.0"},
+		{name: "older scaffold", minScaffoldVersion: "v1.2.0", scaffoldVersion: "1.1.9", expectedErr: "requires scaffold v1.2.0 or newer"},
+		{name: "development build", minScaffoldVersion: "1.2.0", scaffoldVersion: "dev"},
+		{name: "invalid minimum version", minScaffoldVersion: "latest", scaffoldVersion: "1.2.0", expectedErr: "invalid minScaffoldVersion: latest"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template := &Template{File: TemplateFile{Name: "service", MinScaffoldVersion: test.minScaffoldVersion}}
+
+			err := template.CheckUsable(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), test.scaffoldVersion)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, test.expectedErr)
+		})
+	}
+
+	t.Run("warns when deprecated", func(t *testing.T) {
+		var logs bytes.Buffer
+		template := &Template{File: TemplateFile{Name: "service", Deprecated: true, Replacement: "service-v2"}}
+
+		assert.NoError(t, template.CheckUsable(slog.New(slog.NewTextHandler(&logs, nil)), "1.0.0"))
+		assert.Contains(t, logs.String(), "template is deprecated")
+		assert.Contains(t, logs.String(), "replacement=service-v2")
+	})
+
+	t.Run("checks the templates it uses", func(t *testing.T) {
+		var logs bytes.Buffer
+		template := &Template{
+			Registry: "team",
+			File:     TemplateFile{Name: "service"},
+			Dependencies: []Template{{
+				Registry: "team",
+				File:     TemplateFile{Name: "dockerfile", Deprecated: true},
+				Dependencies: []Template{
+					{Registry: "team", File: TemplateFile{Name: "buildkit", MinScaffoldVersion: "2.0.0"}},
+				},
+			}},
+		}
+
+		err := template.CheckUsable(slog.New(slog.NewTextHandler(&logs, nil)), "1.0.0")
+		assert.ErrorContains(t, err, "used template: team/dockerfile, used template: team/buildkit, template: team/buildkit requires scaffold 2.0.0 or newer")
+		assert.Contains(t, logs.String(), "template=team/dockerfile", "a deprecated used template is warned about")
+	})
+}
@@ -0,0 +1,180 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PostProcessor transforms the content of a templated file before it is written, it returns an error if the content isn't valid.
+type PostProcessor func(filePath string, content []byte) ([]byte, error)
+
+// PostProcessorNone can be used in a files postProcess list to disable post processing for that file.
+const PostProcessorNone = "none"
+
+// PostProcessors is a registry of named post processors, and which of them apply by default to a given file extension.
+type PostProcessors struct {
+	processors map[string]PostProcessor
+	extensions map[string][]string
+}
+
+func NewPostProcessors() *PostProcessors {
+	return &PostProcessors{
+		processors: make(map[string]PostProcessor),
+		extensions: make(map[string][]string),
+	}
+}
+
+// DefaultPostProcessors validates yaml and json files, and normalises the trailing newline of yaml, json and markdown files. Go files aren't formatted by default, as templates may render go which only parses once it is wired in, gofmt and yaml-format are opt-in per file
+func DefaultPostProcessors() *PostProcessors {
+	return NewPostProcessors().
+		Register("gofmt", formatGo).
+		Register("yaml", validateYAML).
+		Register("yaml-format", formatYAML).
+		Register("json", formatJSON).
+		Register("newline", normaliseNewline).
+		ForExtension(".yaml", "yaml", "newline").
+		ForExtension(".yml", "yaml", "newline").
+		ForExtension(".json", "json", "newline").
+		ForExtension(".md", "newline").
+		ForExtension(".markdown", "newline")
+}
+
+func (p *PostProcessors) Register(name string, processor PostProcessor) *PostProcessors {
+	p.processors[name] = processor
+
+	return p
+}
+
+// ForExtension sets the post processors which are run by default for files ending in ext, i.e. `.yaml`
+func (p *PostProcessors) ForExtension(ext string, names ...string) *PostProcessors {
+	p.extensions[strings.ToLower(ext)] = names
+
+	return p
+}
+
+// Has returns whether a post processor is registered with the name, none is always known
+func (p *PostProcessors) Has(name string) bool {
+	if name == PostProcessorNone {
+		return true
+	}
+
+	_, ok := p.processors[name]
+
+	return ok
+}
+
+// Defaults returns the post processors registered for the extension of the given file
+func (p *PostProcessors) Defaults(filePath string) []string {
+	return p.extensions[strings.ToLower(path.Ext(filePath))]
+}
+
+// Process runs the named post processors in order over content
+func (p *PostProcessors) Process(filePath string, names []string, content []byte) ([]byte, error) {
+	for _, name := range names {
+		if name == PostProcessorNone {
+			continue
+		}
+
+		processor, ok := p.processors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown post processor: %s", name)
+		}
+
+		processed, err := processor(filePath, content)
+		if err != nil {
+			return nil, fmt.Errorf("post processor %s failed: %w", name, err)
+		}
+
+		content = processed
+	}
+
+	return content, nil
+}
+
+func formatGo(_ string, content []byte) ([]byte, error) {
+	formatted, err := format.Source(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid go file: %w", err)
+	}
+
+	return formatted, nil
+}
+
+// validateYAML checks that every document of the file is valid yaml, and leaves the content as it is
+func validateYAML(_ string, content []byte) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				return content, nil
+			}
+
+			return nil, fmt.Errorf("invalid yaml file: %w", err)
+		}
+	}
+}
+
+// formatYAML re-encodes every document of the file with an indent of 2
+func formatYAML(_ string, content []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return content, nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	output := bytes.NewBufferString("")
+	encoder := yaml.NewEncoder(output)
+	encoder.SetIndent(2)
+
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("invalid yaml file: %w", err)
+		}
+
+		if err := encoder.Encode(&document); err != nil {
+			return nil, fmt.Errorf("failed to encode yaml file: %w", err)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode yaml file: %w", err)
+	}
+
+	return output.Bytes(), nil
+}
+
+func formatJSON(_ string, content []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return content, nil
+	}
+
+	output := bytes.NewBufferString("")
+	if err := json.Indent(output, bytes.TrimSpace(content), "", "  "); err != nil {
+		return nil, fmt.Errorf("invalid json file: %w", err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// normaliseNewline makes sure a file ends with exactly one newline
+func normaliseNewline(_ string, content []byte) ([]byte, error) {
+	trimmed := bytes.TrimRight(content, "\r\n")
+	if len(trimmed) == 0 {
+		return trimmed, nil
+	}
+
+	return append(trimmed, '\n'), nil
+}
@@ -0,0 +1,159 @@
+package templates
+
+import (
+	"bytes"
+	"log/slog"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostProcessors(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor PostProcessor
+		content   string
+		expected  string
+		err       string
+	}{
+		{
+			name:      "yaml is left as it is",
+			processor: validateYAML,
+			content:   "# comment\nkey:   'value'\n\nlist: [a, b]\n---\nother: true\n",
+			expected:  "# comment\nkey:   'value'\n\nlist: [a, b]\n---\nother: true\n",
+		},
+		{
+			name:      "yaml is validated",
+			processor: validateYAML,
+			content:   "key: value\n---\nkey: [invalid\n",
+			err:       "invalid yaml file",
+		},
+		{
+			name:      "empty yaml is valid",
+			processor: validateYAML,
+			content:   "",
+			expected:  "",
+		},
+		{
+			name:      "yaml is re-encoded",
+			processor: formatYAML,
+			content:   "key:   'value'\nlist: [a, b]\n---\nother:\n    nested: true\n",
+			expected:  "key: 'value'\nlist: [a, b]\n---\nother:\n  nested: true\n",
+		},
+		{
+			name:      "invalid yaml isn't re-encoded",
+			processor: formatYAML,
+			content:   "key: [invalid\n",
+			err:       "invalid yaml file",
+		},
+		{
+			name:      "json is indented",
+			processor: formatJSON,
+			content:   "\n{\"key\": [1,2]}\n",
+			expected:  "{\n  \"key\": [\n    1,\n    2\n  ]\n}",
+		},
+		{
+			name:      "json is validated",
+			processor: formatJSON,
+			content:   "{\"key\": }",
+			err:       "invalid json file",
+		},
+		{
+			name:      "trailing newlines are collapsed",
+			processor: normaliseNewline,
+			content:   "# title\r\n\n\n",
+			expected:  "# title\n",
+		},
+		{
+			name:      "a missing newline is added",
+			processor: normaliseNewline,
+			content:   "# title",
+			expected:  "# title\n",
+		},
+		{
+			name:      "only newlines become empty",
+			processor: normaliseNewline,
+			content:   "\n\n",
+			expected:  "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := test.processor("file", []byte(test.content))
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(actual))
+		})
+	}
+}
+
+func TestPostProcessorsProcess(t *testing.T) {
+	postProcessors := DefaultPostProcessors()
+
+	assert.Equal(t, []string{"yaml", "newline"}, postProcessors.Defaults("deploy/values.YML"))
+	assert.Empty(t, postProcessors.Defaults("Dockerfile"))
+	assert.Empty(t, postProcessors.Defaults("main.go"), "gofmt is opt-in")
+
+	content, err := postProcessors.Process("notes.md", []string{PostProcessorNone}, []byte("# notes\n\n\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "# notes\n\n\n", string(content), "none leaves the content as it is")
+
+	content, err = postProcessors.Process("settings.json", []string{"json", "newline"}, []byte("{\"a\":1}"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": 1\n}\n", string(content), "the post processors run in order")
+
+	_, err = postProcessors.Process("main.go", []string{"prettier"}, []byte("package main\n"))
+	assert.ErrorContains(t, err, "unknown post processor: prettier")
+
+	_, err = postProcessors.Process("main.go", []string{"gofmt"}, []byte("package main\nfunc {"))
+	assert.ErrorContains(t, err, "post processor gofmt failed: invalid go file")
+}
+
+func TestTemplateLoaderPostProcess(t *testing.T) {
+	template := &Template{
+		File: TemplateFile{
+			Name: "post",
+			Files: map[string]TemplateFileConfig{
+				"formatted.go":  {PostProcess: []string{"gofmt"}},
+				"settings.json": {PostProcess: []string{"json"}},
+				"appended.go":   {Mode: "append"},
+			},
+		},
+		Input: map[string]string{},
+	}
+	files := []File{
+		{content: []byte("package main\nfunc main()  {}\n"), RelPath: "main.go"},
+		{content: []byte("package main\nfunc main()  {}\n"), RelPath: "formatted.go"},
+		{content: []byte("# values\nkey:   value\n\n\n"), RelPath: "values.yaml"},
+		{content: []byte("{\"a\":1}"), RelPath: "settings.json"},
+		{content: []byte("func other()  {}\n"), RelPath: "appended.go"},
+	}
+
+	dest := t.TempDir()
+	templatedFiles, err := NewTemplateLoader(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))).TemplateFiles(template, files, dest)
+	require.NoError(t, err)
+
+	actual := make(map[string]string)
+	for _, file := range templatedFiles {
+		actual[file.DestinationPath] = string(file.Content)
+	}
+	assert.Equal(t, map[string]string{
+		path.Join(dest, "main.go"):       "package main\nfunc main()  {}\n",
+		path.Join(dest, "formatted.go"):  "package main\n\nfunc main() {}\n",
+		path.Join(dest, "values.yaml"):   "# values\nkey:   value\n",
+		path.Join(dest, "settings.json"): "{\n  \"a\": 1\n}",
+		path.Join(dest, "appended.go"):   "func other()  {}\n",
+	}, actual)
+
+	_, err = NewTemplateLoader(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))).TemplateFiles(template, []File{
+		{content: []byte("key: [invalid\n"), RelPath: "values.yaml"},
+	}, dest)
+	assert.ErrorContains(t, err, "invalid yaml file")
+}
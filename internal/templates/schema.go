@@ -0,0 +1,255 @@
+package templates
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaURL is where the schema of scaffold.yaml is published, for editors using yaml-language-server
+const SchemaURL = "https://raw.githubusercontent.com/kjuulh/scaffold/main/schemas/scaffold.schema.json"
+
+// Schema is the subset of JSON Schema needed to describe scaffold.yaml
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties is either false, or the schema of the values of a map
+	AdditionalProperties any      `json:"additionalProperties,omitempty"`
+	Items                *Schema  `json:"items,omitempty"`
+	Enum                 []string `json:"enum,omitempty"`
+}
+
+// schemaDescriptions are the descriptions of the properties of scaffold.yaml, by their path. Map keys are *, and items of lists are []
+var schemaDescriptions = map[string]string{
+	"name":                  "The name of the template, it is used as the command to scaffold it",
+	"extends":               "The template to inherit the inputs, files and hooks of, by its name or full name. Inputs and files can be added or overridden one by one",
+	"description":           "What the template scaffolds, shown when the templates are listed",
+	"tags":                  "Tags to filter the templates by",
+	"owners":                "Who to reach out to about the template, i.e. a team or an email",
+	"version":               "The version of the template",
+	"deprecated":            "Deprecated templates are still offered, but warn when used",
+	"replacement":           "The template to use instead of a deprecated template",
+	"minScaffoldVersion":    "The oldest version of scaffold which can use the template, i.e. 1.2.0",
+	"default":               "The defaults of the template",
+	"default.path":          "Where the files are scaffolded to, a go template with the inputs available, i.e. internal/{{ .Input.name }}",
+	"input":                 "The inputs of the template, by their name. They are available as .Input.<name> in the files",
+	"input.*.type":          "The type of the input",
+	"input.*.description":   "What the input is used for, shown when prompting for it",
+	"input.*.default":       "The value of the input if none is given",
+	"files":                 "The configuration of the files in files/, by their path without the .gotmpl extension",
+	"files.*.rename":        "Where to write the file instead, a go template with the inputs, OriginalFileName and OriginalFilePath available",
+	"files.*.postProcess":   "The post processors to run for the file instead of the ones for its extension, none disables them",
+	"files.*.postProcess[]": "A post processor",
+	"files.*.mode":          "How the file is written",
+	"files.*.anchor":        "The line to inject the content next to, for the inject mode",
+	"files.*.position":      "Whether to inject the content before or after the anchor, defaults to after",
+	"files.*.target":        "The go function, struct or interface to add the content to, for the go modes",
+	"hooks":                 "Shell commands run when scaffolding the template",
+	"hooks.pre":             "Commands run before the files are written",
+	"hooks.post":            "Commands run after the files are written",
+	"uses":                  "Other templates which are scaffolded together with the template, into the same destination",
+	"uses[].template":       "The name of the template to use, or its full name, i.e. default/go/http/externalhttp",
+	"uses[].input":          "The inputs of the used template, the values are go templates with the inputs of this template, i.e. {{ .Input.name }}",
+	"uses[].path":           "Where the used template is scaffolded to, relative to this template. Defaults to the default path of the used template",
+}
+
+// schemaRequired are the required properties of the objects in scaffold.yaml, by their path
+var schemaRequired = map[string][]string{
+	"":       {"name"},
+	"uses[]": {"template"},
+}
+
+// TemplateFileSchema returns the JSON Schema of scaffold.yaml, generated from TemplateFile
+func TemplateFileSchema() *Schema {
+	postProcessors := slices.Sorted(maps.Keys(DefaultPostProcessors().processors))
+
+	enums := map[string][]string{
+		"files.*.mode":          slices.Sorted(maps.Keys(writeModes)),
+		"files.*.position":      {string(InjectPositionBefore), string(InjectPositionAfter)},
+		"files.*.postProcess[]": append(postProcessors, PostProcessorNone),
+	}
+
+	schema := reflectSchema(reflect.TypeFor[TemplateFile](), "", enums)
+	schema.Schema = "http://json-schema.org/draft-07/schema#"
+	schema.ID = SchemaURL
+	schema.Title = "scaffold.yaml"
+	schema.Description = "A scaffold template"
+
+	return schema
+}
+
+func reflectSchema(t reflect.Type, schemaPath string, enums map[string][]string) *Schema {
+	schema := &Schema{Description: schemaDescriptions[schemaPath]}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		schema.Type = "object"
+		schema.Properties = make(map[string]*Schema)
+		schema.AdditionalProperties = false
+		schema.Required = schemaRequired[schemaPath]
+
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+
+			schema.Properties[name] = reflectSchema(field.Type, joinSchemaPath(schemaPath, name), enums)
+		}
+	case reflect.Map:
+		schema.Type = "object"
+		schema.AdditionalProperties = reflectSchema(t.Elem(), joinSchemaPath(schemaPath, "*"), enums)
+	case reflect.Slice:
+		schema.Type = "array"
+		schema.Items = reflectSchema(t.Elem(), schemaPath+"[]", enums)
+	case reflect.Bool:
+		schema.Type = "boolean"
+	case reflect.Int, reflect.Int64:
+		schema.Type = "integer"
+	default:
+		schema.Type = "string"
+		schema.Enum = enums[schemaPath]
+	}
+
+	return schema
+}
+
+func joinSchemaPath(schemaPath string, name string) string {
+	if schemaPath == "" {
+		return name
+	}
+
+	return schemaPath + "." + name
+}
+
+// templateFileSchema is generated once, as it is used for every template that is indexed
+var templateFileSchema = sync.OnceValue(TemplateFileSchema)
+
+// SchemaError is where a yaml document doesn't match the schema
+type SchemaError struct {
+	Line int
+	// Path is the property which doesn't match, i.e. files.main.go.mode
+	Path    string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+}
+
+// Validate checks the yaml content against the schema, it only fails if the content isn't yaml
+func (s *Schema) Validate(content []byte) ([]SchemaError, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	if len(document.Content) == 0 {
+		return s.validateNode(&yaml.Node{Kind: yaml.MappingNode, Line: 1}, ""), nil
+	}
+
+	return s.validateNode(document.Content[0], ""), nil
+}
+
+func (s *Schema) validateNode(node *yaml.Node, nodePath string) []SchemaError {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	// An empty value is the same as leaving it out
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	fail := func(message string, args ...any) []SchemaError {
+		return []SchemaError{{Line: node.Line, Path: nodePath, Message: fmt.Sprintf(message, args...)}}
+	}
+
+	switch s.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			return fail("expected an object")
+		}
+
+		errs := make([]SchemaError, 0)
+		keys := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keys[key.Value] = true
+			valuePath := joinSchemaPath(nodePath, key.Value)
+
+			if property, ok := s.Properties[key.Value]; ok {
+				errs = append(errs, property.validateNode(value, valuePath)...)
+				continue
+			}
+
+			switch additional := s.AdditionalProperties.(type) {
+			case *Schema:
+				errs = append(errs, additional.validateNode(value, valuePath)...)
+			case bool:
+				if !additional {
+					errs = append(errs, SchemaError{Line: key.Line, Path: nodePath, Message: fmt.Sprintf("unknown key: %s", key.Value)})
+				}
+			}
+		}
+
+		for _, required := range s.Required {
+			if !keys[required] {
+				errs = append(errs, SchemaError{Line: node.Line, Path: nodePath, Message: fmt.Sprintf("%s is required", required)})
+			}
+		}
+
+		return errs
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			return fail("expected a list")
+		}
+
+		errs := make([]SchemaError, 0)
+		for i, item := range node.Content {
+			errs = append(errs, s.Items.validateNode(item, fmt.Sprintf("%s[%d]", nodePath, i))...)
+		}
+
+		return errs
+	case "boolean":
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			return fail("expected true or false")
+		}
+	case "integer":
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			return fail("expected a number")
+		}
+	case "string":
+		if node.Kind != yaml.ScalarNode {
+			return fail("expected a string")
+		}
+
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, node.Value) {
+			return fail("'%s' must be one of: %s", node.Value, strings.Join(s.Enum, ", "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateFileSchemaIsUpToDate(t *testing.T) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	require.NoError(t, encoder.Encode(TemplateFileSchema()))
+
+	published, err := os.ReadFile("../../schemas/scaffold.schema.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, string(published), buffer.String(), "the published schema is out of date, run: go run . schema > schemas/scaffold.schema.json")
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schemaErrs, err := TemplateFileSchema().Validate([]byte(`name: service
+deprecated: "yes"
+tags: go
+input:
+  name:
+    type: string
+    descripton: typo
+files:
+  main.go:
+    mode: overwrite
+    postProcess: [gofmt, prettier]
+  other.go:
+    position: after
+hooks:
+  post:
+    - go mod tidy
+`))
+	require.NoError(t, err)
+
+	actual := make([]string, 0, len(schemaErrs))
+	for _, schemaErr := range schemaErrs {
+		actual = append(actual, schemaErr.Error())
+	}
+
+	assert.Equal(t, []string{
+		"line 2: deprecated: expected true or false",
+		"line 3: tags: expected a list",
+		"line 7: input.name: unknown key: descripton",
+		"line 10: files.main.go.mode: 'overwrite' must be one of: append, go-func, go-import, go-interface, go-struct, inject, merge-json, merge-yaml, prepend, skip-if-exists, write",
+		"line 11: files.main.go.postProcess[1]: 'prettier' must be one of: gofmt, json, newline, yaml, yaml-format, none",
+	}, actual)
+
+	schemaErrs, err = TemplateFileSchema().Validate([]byte("description: no name\n"))
+	require.NoError(t, err)
+	require.Len(t, schemaErrs, 1)
+	assert.Equal(t, "line 1: name is required", schemaErrs[0].Error())
+
+	_, err = TemplateFileSchema().Validate([]byte("name: [invalid\n"))
+	assert.Error(t, err)
+}
@@ -2,18 +2,33 @@ package templates
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"maps"
 	"os"
 	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/kjuulh/scaffold/internal/fetcher"
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 )
 
+// TemplateFileName is the specification of a template, a folder in the registry with one is a template
+const TemplateFileName = "scaffold.yaml"
+
 // TemplateIndexer loads all template specifications from the registry, allowing the caller to choose on of them, or simply display their properties.
-type TemplateIndexer struct{}
+// Templates which can't be loaded are skipped, and kept as diagnostics, so one broken template doesn't break the whole registry
+type TemplateIndexer struct {
+	diagnostics     []Diagnostic
+	diagnosticsLock sync.Mutex
+}
 
 func NewTemplateIndexer() *TemplateIndexer {
 	return &TemplateIndexer{}
@@ -22,10 +37,32 @@ func NewTemplateIndexer() *TemplateIndexer {
 type Template struct {
 	File TemplateFile
 	Path string
+	// Category is the folder of the template in the registry, i.e. go/http. Empty if the template is at the root of the registry
+	Category string
+	// Registry is the name of the registry the template comes from, empty if it isn't namespaced
+	Registry string
+	// Commit is the commit of the registry the template was indexed from, if it is a git registry, or the checksum of an http registry
+	Commit string
+	// Dependencies are the templates in File.Uses, in the same order, as resolved by the indexer
+	Dependencies []Template
+	// Base is the template in File.Extends as resolved by the indexer, File already includes what is inherited from it
+	Base *Template
 
 	Input map[string]string
 }
 
+// FullName is the name the template is addressable by, namespaced by its registry and category, i.e. team/go/http/externalhttp
+func (t *Template) FullName() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{t.Registry, t.Category, t.File.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
 type TemplateDefault struct {
 	Path string `yaml:"path"`
 }
@@ -39,59 +76,311 @@ type TemplateInput struct {
 
 type TemplateFileConfig struct {
 	Rename string `yaml:"rename"`
+	// PostProcess overrides the post processors run for the file, defaults to the ones registered for its extension. Use `none` to disable them
+	PostProcess []string `yaml:"postProcess,omitempty"`
+	// Mode is how the file is written: write, append, prepend, skip-if-exists, inject, merge-yaml, merge-json, go-import, go-func, go-struct or go-interface
+	Mode string `yaml:"mode,omitempty"`
+	// Anchor and Position are used by the inject mode
+	Anchor   string         `yaml:"anchor,omitempty"`
+	Position InjectPosition `yaml:"position,omitempty"`
+	// Target is the go function, struct or interface used by the go modes
+	Target string `yaml:"target,omitempty"`
+}
+
+// TemplateUse is another template scaffolded along with a template, i.e. a service template using the dockerfile template
+type TemplateUse struct {
+	// Template is the name of the template to use, or its full name, i.e. default/go/http/externalhttp
+	Template string `yaml:"template"`
+	// Input maps the inputs of the used template, the values are go templates with the inputs of the template using it, i.e. "{{ .Input.name }}". Inputs which aren't mapped use their default
+	Input map[string]string `yaml:"input,omitempty"`
+	// Path is where the used template is scaffolded to, relative to the template using it. Defaults to the default path of the used template
+	Path string `yaml:"path,omitempty"`
+}
+
+// TemplateHooks are shell commands run before and after the files are written
+type TemplateHooks struct {
+	Pre  []string `yaml:"pre,omitempty"`
+	Post []string `yaml:"post,omitempty"`
 }
 
 type TemplateFile struct {
-	Name    string                        `yaml:"name"`
-	Default TemplateDefault               `yaml:"default"`
-	Input   TemplateInputs                `yaml:"input"`
-	Files   map[string]TemplateFileConfig `yaml:"files"`
+	Name string `yaml:"name"`
+	// Extends is the template this template inherits its inputs, files and hooks from, by its name or full name. Inputs and files can be added or overridden one by one
+	Extends string `yaml:"extends,omitempty"`
+	// Description and Tags describe the template when it is listed, tags can be used to filter the templates
+	Description string   `yaml:"description,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	// Owners are who to reach out to about the template, i.e. a team or an email
+	Owners  []string `yaml:"owners,omitempty"`
+	Version string   `yaml:"version,omitempty"`
+	// Deprecated templates are still offered, but warn when used. Replacement is the template to use instead
+	Deprecated  bool   `yaml:"deprecated,omitempty"`
+	Replacement string `yaml:"replacement,omitempty"`
+	// MinScaffoldVersion is the oldest scaffold which can use the template, i.e. when it relies on a newer write mode
+	MinScaffoldVersion string                        `yaml:"minScaffoldVersion,omitempty"`
+	Default            TemplateDefault               `yaml:"default"`
+	Input              TemplateInputs                `yaml:"input"`
+	Files              map[string]TemplateFileConfig `yaml:"files"`
+	Hooks              TemplateHooks                 `yaml:"hooks,omitempty"`
+	// Uses are other templates which are scaffolded together with the template, into the same destination
+	Uses []TemplateUse `yaml:"uses,omitempty"`
+}
+
+// FileConfig returns the configuration in scaffold.yaml for a file in the templates files folder
+func (t *Template) FileConfig(relPath string) TemplateFileConfig {
+	return t.File.Files[strings.TrimSuffix(relPath, ".gotmpl")]
 }
 
+// IndexSources fetches the registries from their sources in parallel, and indexes their templates. The templates are returned in the order of the sources
+func (t *TemplateIndexer) IndexSources(ctx context.Context, sources []fetcher.RegistrySource, ui *slog.Logger) ([]Template, error) {
+	sourceTemplates := make([][]Template, len(sources))
+	egrp, ctx := errgroup.WithContext(ctx)
+	for i, source := range sources {
+		egrp.Go(func() error {
+			registry, err := source.Fetch(ctx, ui)
+			if err != nil {
+				return fmt.Errorf("registry: %s, %w", source.Name(), err)
+			}
+
+			templates, err := t.IndexRegistry(ctx, registry, ui)
+			if err != nil {
+				return fmt.Errorf("registry: %s, %w", source.Name(), err)
+			}
+
+			sourceTemplates[i] = templates
+
+			return nil
+		})
+	}
+
+	if err := egrp.Wait(); err != nil {
+		return nil, err
+	}
+
+	templates := make([]Template, 0)
+	for _, registryTemplates := range sourceTemplates {
+		templates = append(templates, registryTemplates...)
+	}
+
+	return t.Resolve(templates), nil
+}
+
+// IndexRegistry indexes the templates of a single registry on disk, and namespaces them with its name. The templates they use aren't resolved, as they may be in other registries, see Resolve
+func (t *TemplateIndexer) IndexRegistry(ctx context.Context, registry fetcher.LocalRegistry, ui *slog.Logger) ([]Template, error) {
+	templates, diagnostics, err := t.index(ctx, registry.Path, ui)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range templates {
+		templates[i].Registry = registry.Name
+		templates[i].Commit = registry.Commit
+	}
+
+	for i := range diagnostics {
+		diagnostics[i].Registry = registry.Name
+	}
+	t.addDiagnostics(diagnostics)
+
+	return templates, nil
+}
+
+// Index finds the templates in the registry folder. A folder with a scaffold.yaml is a template, and the folders above it are its category, i.e. go/http/externalhttp. Hidden folders, and files and folders which aren't templates, are skipped
+// Templates which can't be loaded are skipped as well, and are available from Diagnostics. Only a registry folder which can't be read is an error
 func (t *TemplateIndexer) Index(ctx context.Context, scaffoldRegistryFolder string, ui *slog.Logger) ([]Template, error) {
+	templates, diagnostics, err := t.index(ctx, scaffoldRegistryFolder, ui)
+	if err != nil {
+		return nil, err
+	}
+
+	t.addDiagnostics(diagnostics)
+
+	return t.Resolve(templates), nil
+}
+
+func (t *TemplateIndexer) index(ctx context.Context, scaffoldRegistryFolder string, ui *slog.Logger) ([]Template, []Diagnostic, error) {
 	ui.Debug("Loading templates...")
 
-	templateDirEntries, err := os.ReadDir(scaffoldRegistryFolder)
+	templateDirs, err := findTemplateDirs(scaffoldRegistryFolder, ui)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read templates dir: %s, %w", scaffoldRegistryFolder, err)
+		return nil, nil, err
 	}
 
 	var (
-		templates     = make([]Template, 0)
-		templatesLock sync.Mutex
+		loadedTemplates = make([]*Template, len(templateDirs))
+		loadErrs        = make([]error, len(templateDirs))
+		schemaErrs      = make([][]SchemaError, len(templateDirs))
 	)
-	egrp, _ := errgroup.WithContext(ctx)
-	for _, templateDirEntry := range templateDirEntries {
+	egrp, ctx := errgroup.WithContext(ctx)
+	for i, templateDir := range templateDirs {
 		egrp.Go(func() error {
-			templatePath := path.Join(scaffoldRegistryFolder, templateDirEntry.Name())
+			// A template which can't be loaded is only a diagnostic, but a cancelled run stops loading the rest
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 
-			content, err := os.ReadFile(path.Join(templatePath, "scaffold.yaml"))
+			template, templateSchemaErrs, err := loadTemplate(scaffoldRegistryFolder, templateDir)
 			if err != nil {
-				return fmt.Errorf("failed to read: %s, %w", templateDirEntry.Name(), err)
+				ui.Debug("skipping template which can't be loaded", "path", templateDir, "error", err)
+				loadErrs[i] = err
+				return nil
 			}
 
-			var template TemplateFile
-			if err := yaml.Unmarshal(content, &template); err != nil {
-				return fmt.Errorf("failed to unmarshal template: %s, %w", string(content), err)
-			}
-
-			templatesLock.Lock()
-			defer templatesLock.Unlock()
-			templates = append(templates, Template{
-				File:  template,
-				Path:  templatePath,
-				Input: make(map[string]string),
-			})
+			loadedTemplates[i] = template
+			schemaErrs[i] = templateSchemaErrs
 
 			return nil
 		})
 	}
 
 	if err := egrp.Wait(); err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("failed to load templates: %s, %w", scaffoldRegistryFolder, err)
 	}
 
-	ui.Debug("Done loading templates...", "amount", len(templates))
+	var (
+		templates   = make([]Template, 0, len(templateDirs))
+		diagnostics = make([]Diagnostic, 0)
+	)
+	for i, templateDir := range templateDirs {
+		if loadErrs[i] != nil {
+			diagnostics = append(diagnostics, Diagnostic{Path: templateDir, Err: loadErrs[i]})
+			continue
+		}
 
-	return templates, nil
+		for _, schemaErr := range schemaErrs[i] {
+			diagnostics = append(diagnostics, Diagnostic{
+				Path:    templateDir,
+				Err:     fmt.Errorf("%s doesn't match the schema: %w", TemplateFileName, schemaErr),
+				Warning: true,
+			})
+		}
+
+		templates = append(templates, *loadedTemplates[i])
+	}
+
+	ui.Debug("Done loading templates...", "amount", len(templates), "problems", len(diagnostics))
+
+	return templates, diagnostics, nil
+}
+
+// loadTemplate reads and validates the scaffold.yaml of the template in templateDir, relative to the registry folder. Where it doesn't match the schema is returned separately, as the template can still be used
+func loadTemplate(scaffoldRegistryFolder string, templateDir string) (*Template, []SchemaError, error) {
+	templatePath := path.Join(scaffoldRegistryFolder, templateDir)
+
+	content, err := os.ReadFile(path.Join(templatePath, TemplateFileName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", TemplateFileName, err)
+	}
+
+	var template TemplateFile
+	if err := yaml.Unmarshal(content, &template); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", TemplateFileName, err)
+	}
+
+	if template.Name == "" {
+		return nil, nil, fmt.Errorf("invalid %s: name is required", TemplateFileName)
+	}
+
+	if strings.Contains(template.Name, "/") {
+		return nil, nil, fmt.Errorf("invalid %s: name: %s can't contain /, use folders to put the template in a category", TemplateFileName, template.Name)
+	}
+
+	for _, relPath := range slices.Sorted(maps.Keys(template.Files)) {
+		if _, err := ParseInjectPosition(string(template.Files[relPath].Position)); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: files.%s.position: %w", TemplateFileName, relPath, err)
+		}
+	}
+
+	schemaErrs, err := templateFileSchema().Validate(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", TemplateFileName, err)
+	}
+
+	category := path.Dir(templateDir)
+	if category == "." {
+		category = ""
+	}
+
+	return &Template{
+		File:     template,
+		Path:     templatePath,
+		Category: category,
+		Input:    make(map[string]string),
+	}, schemaErrs, nil
+}
+
+// findTemplateDirs walks the registry folder for folders with a scaffold.yaml, relative to the registry folder and sorted. It doesn't descend into templates, so their files and testdata are never mistaken for templates
+func findTemplateDirs(scaffoldRegistryFolder string, ui *slog.Logger) ([]string, error) {
+	var (
+		templateDirs = make([]string, 0)
+		categoryDirs = make([]string, 0)
+		hasChildren  = make(map[string]bool)
+	)
+
+	err := filepath.WalkDir(scaffoldRegistryFolder, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(scaffoldRegistryFolder, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." {
+			return nil
+		}
+
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			// Symlinked templates are followed, but not walked
+			if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+				isDir = true
+			}
+		}
+
+		if !isDir {
+			ui.Debug("skipping file in registry, it isn't a template", "path", relPath)
+			return nil
+		}
+
+		hasChildren[path.Dir(relPath)] = true
+
+		if _, err := os.Stat(filepath.Join(filePath, TemplateFileName)); err == nil {
+			templateDirs = append(templateDirs, relPath)
+
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read: %s, %w", relPath, err)
+		}
+
+		categoryDirs = append(categoryDirs, relPath)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates dir: %s, %w", scaffoldRegistryFolder, err)
+	}
+
+	for _, categoryDir := range categoryDirs {
+		if !hasChildren[categoryDir] {
+			ui.Warn("skipping folder in registry, it has no scaffold.yaml", "path", categoryDir)
+		}
+	}
+
+	sort.Strings(templateDirs)
+
+	return templateDirs, nil
 }
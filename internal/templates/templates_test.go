@@ -0,0 +1,99 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/kjuulh/scaffold/internal/fetcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateIndexerIndex(t *testing.T) {
+	registryPath := t.TempDir()
+	for filePath, content := range map[string]string{
+		"README.md":                                "# registry\n",
+		".git/config":                              "",
+		".github/scaffold.yaml":                    "name: hidden\n",
+		"service/scaffold.yaml":                    "name: service\n",
+		"service/files/main.go":                    "package main\n",
+		"service/testdata/nested/scaffold.yaml":    "name: nested\n",
+		"go/http/externalhttp/scaffold.yaml":       "name: externalhttp\n",
+		"go/http/externalhttp/files/client.go":     "package client\n",
+		"go/library/scaffold.yaml":                 "name: library\n",
+		"go/grpc/README.md":                        "not a template yet\n",
+		"docs/guides/writing-templates/index.html": "",
+	} {
+		require.NoError(t, os.MkdirAll(path.Dir(path.Join(registryPath, filePath)), 0o755))
+		require.NoError(t, os.WriteFile(path.Join(registryPath, filePath), []byte(content), 0o644))
+	}
+
+	var logs bytes.Buffer
+	templates, err := NewTemplateIndexer().Index(t.Context(), registryPath, slog.New(slog.NewTextHandler(&logs, nil)))
+	require.NoError(t, err)
+
+	fullNames := make([]string, 0, len(templates))
+	for _, template := range templates {
+		fullNames = append(fullNames, template.FullName())
+	}
+	assert.Equal(t, []string{"go/http/externalhttp", "go/library", "service"}, fullNames)
+
+	assert.Equal(t, "go/http", templates[0].Category)
+	assert.Equal(t, path.Join(registryPath, "go/http/externalhttp"), templates[0].Path)
+	assert.Equal(t, "", templates[2].Category)
+
+	templates[0].Registry = "team"
+	assert.Equal(t, "team/go/http/externalhttp", templates[0].FullName())
+
+	assert.Contains(t, logs.String(), "path=go/grpc", "a folder without templates is warned about")
+	assert.Contains(t, logs.String(), "path=docs/guides/writing-templates")
+	assert.NotContains(t, logs.String(), "path=docs\n", "only the innermost folder is warned about")
+}
+
+func TestTemplateIndexerDiagnostics(t *testing.T) {
+	registryPath := t.TempDir()
+	for filePath, content := range map[string]string{
+		"service/scaffold.yaml":    "name: service\n",
+		"broken/scaffold.yaml":     "name: [broken\n",
+		"go/unnamed/scaffold.yaml": "description: no name\n",
+		"typo/scaffold.yaml":       "name: typo\ndescripton: a typo\n",
+		"position/scaffold.yaml":   "name: position\nfiles:\n  main.go:\n    position: above\n",
+	} {
+		require.NoError(t, os.MkdirAll(path.Dir(path.Join(registryPath, filePath)), 0o755))
+		require.NoError(t, os.WriteFile(path.Join(registryPath, filePath), []byte(content), 0o644))
+	}
+
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	indexer := NewTemplateIndexer()
+
+	templates, err := indexer.IndexRegistry(t.Context(), fetcher.LocalRegistry{Name: "team", Path: registryPath}, ui)
+	require.NoError(t, err, "broken templates don't fail the registry")
+	require.Len(t, templates, 2)
+	assert.Equal(t, "team/service", templates[0].FullName())
+	assert.Equal(t, "team/typo", templates[1].FullName(), "a template which doesn't match the schema is still used")
+
+	diagnostics := indexer.Diagnostics()
+	require.Len(t, diagnostics, 4)
+	assert.Equal(t, "team/broken", diagnostics[0].Template())
+	assert.ErrorContains(t, diagnostics[0], "failed to parse scaffold.yaml")
+	assert.Equal(t, "team/go/unnamed", diagnostics[1].Template())
+	assert.ErrorContains(t, diagnostics[1], "name is required")
+	assert.Equal(t, "team/position", diagnostics[2].Template())
+	assert.ErrorContains(t, diagnostics[2], "invalid scaffold.yaml: files.main.go.position: invalid inject position: 'above'")
+	assert.Equal(t, "team/typo", diagnostics[3].Template())
+	assert.ErrorContains(t, diagnostics[3], "line 2: unknown key: descripton")
+	assert.True(t, diagnostics[3].Warning)
+	assert.Equal(t, 3, SkippedTemplates(diagnostics))
+
+	_, err = indexer.Index(t.Context(), path.Join(registryPath, "missing"), ui)
+	assert.Error(t, err, "a registry which can't be read is still an error")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = NewTemplateIndexer().Index(ctx, registryPath, ui)
+	assert.ErrorIs(t, err, context.Canceled, "a cancelled run stops loading the templates")
+}
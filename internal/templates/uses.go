@@ -0,0 +1,222 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	gotmpl "text/template"
+)
+
+// Resolve finds the templates each of the templates extends and uses, among all the given templates. Templates extending or using a template which can't be found, or themselves, are skipped and kept as diagnostics
+func (t *TemplateIndexer) Resolve(templates []Template) []Template {
+	var (
+		extendedTemplates = make([]Template, 0, len(templates))
+		resolvedTemplates = make([]Template, 0, len(templates))
+		diagnostics       = make([]Diagnostic, 0)
+	)
+
+	diagnose := func(template Template, err error) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Registry: template.Registry,
+			Path:     path.Join(template.Category, path.Base(template.Path)),
+			Err:      err,
+		})
+	}
+
+	// The templates are extended first, so the templates they use are the extended ones
+	for _, template := range templates {
+		extended, err := resolveBase(templates, template, nil)
+		if err != nil {
+			diagnose(template, err)
+			continue
+		}
+
+		extendedTemplates = append(extendedTemplates, extended)
+	}
+
+	for _, template := range extendedTemplates {
+		resolved, err := resolveTemplate(extendedTemplates, template, nil)
+		if err != nil {
+			diagnose(template, err)
+			continue
+		}
+
+		resolvedTemplates = append(resolvedTemplates, resolved)
+	}
+
+	t.addDiagnostics(diagnostics)
+
+	return resolvedTemplates
+}
+
+// resolveTemplate resolves the templates used by template recursively, the stack is the full names of the templates using it, to detect cycles
+func resolveTemplate(templates []Template, template Template, stack []string) (Template, error) {
+	stack = append(slices.Clone(stack), template.FullName())
+
+	template.Dependencies = make([]Template, 0, len(template.File.Uses))
+	for _, use := range template.File.Uses {
+		dependency, err := findTemplate(templates, &template, use.Template)
+		if err != nil {
+			return Template{}, fmt.Errorf("uses: %w", err)
+		}
+
+		if slices.Contains(stack, dependency.FullName()) {
+			return Template{}, fmt.Errorf("template uses itself: %s -> %s", strings.Join(stack, " -> "), dependency.FullName())
+		}
+
+		resolvedDependency, err := resolveTemplate(templates, *dependency, stack)
+		if err != nil {
+			return Template{}, err
+		}
+
+		// The used template gets its own inputs, as they are mapped from the template using it
+		resolvedDependency.Input = make(map[string]string)
+		template.Dependencies = append(template.Dependencies, resolvedDependency)
+	}
+
+	return template, nil
+}
+
+// findTemplate finds the template by its full name, by its name in the registry of the template using it, or by its plain name if it is unique
+func findTemplate(templates []Template, from *Template, name string) (*Template, error) {
+	for i := range templates {
+		if templates[i].FullName() == name {
+			return &templates[i], nil
+		}
+	}
+
+	if from.Registry != "" {
+		for i := range templates {
+			if templates[i].Registry == from.Registry && templates[i].FullName() == path.Join(from.Registry, name) {
+				return &templates[i], nil
+			}
+		}
+	}
+
+	var found *Template
+	for i := range templates {
+		if templates[i].File.Name != name {
+			continue
+		}
+
+		if found != nil {
+			return nil, fmt.Errorf("template: %s is ambiguous, as both %s and %s have the name, use its full name", name, found.FullName(), templates[i].FullName())
+		}
+
+		found = &templates[i]
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("template: %s not found", name)
+	}
+
+	return found, nil
+}
+
+// UsedTemplate is a template used by another template, with its inputs mapped and the destination it is scaffolded into
+type UsedTemplate struct {
+	Template    *Template
+	Destination string
+}
+
+// ResolveDependencies maps the inputs and destinations of the templates used by template, and the ones they use in turn, in the order their files are written
+func (l *TemplateLoader) ResolveDependencies(template *Template, scaffoldDest string) ([]UsedTemplate, error) {
+	if len(template.Dependencies) != len(template.File.Uses) {
+		return nil, fmt.Errorf("the templates used by: %s haven't been resolved", template.FullName())
+	}
+
+	usedTemplates := make([]UsedTemplate, 0)
+	for i, use := range template.File.Uses {
+		dependency := template.Dependencies[i]
+
+		dependency.Input = make(map[string]string)
+		for name, input := range dependency.File.Input {
+			dependency.Input[name] = input.Default
+		}
+
+		for name, value := range use.Input {
+			if _, ok := dependency.File.Input[name]; !ok {
+				return nil, fmt.Errorf("used template: %s has no input: %s", dependency.FullName(), name)
+			}
+
+			rendered, err := renderString(name, value, template)
+			if err != nil {
+				return nil, fmt.Errorf("failed to map input: %s of used template: %s, %w", name, dependency.FullName(), err)
+			}
+
+			dependency.Input[name] = rendered
+		}
+
+		dependencyPath, err := TemplatePath(&dependency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to template the path of used template: %s, %w", dependency.FullName(), err)
+		}
+
+		if use.Path != "" {
+			dependencyPath, err = renderString("path", use.Path, template)
+			if err != nil {
+				return nil, fmt.Errorf("failed to template the path of used template: %s, %w", dependency.FullName(), err)
+			}
+		}
+
+		dependencyDest := path.Join(scaffoldDest, dependencyPath)
+		usedTemplates = append(usedTemplates, UsedTemplate{Template: &dependency, Destination: dependencyDest})
+
+		nested, err := l.ResolveDependencies(&dependency, dependencyDest)
+		if err != nil {
+			return nil, err
+		}
+		usedTemplates = append(usedTemplates, nested...)
+	}
+
+	return usedTemplates, nil
+}
+
+// TemplateDependencies templates the files of the templates used by template, into its destination. The inputs of the used templates are mapped from the inputs of template
+func (l *TemplateLoader) TemplateDependencies(ctx context.Context, template *Template, scaffoldDest string) ([]TemplatedFile, error) {
+	usedTemplates, err := l.ResolveDependencies(template, scaffoldDest)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.TemplateUsed(ctx, template, usedTemplates)
+}
+
+// TemplateUsed templates the files of the templates resolved by ResolveDependencies, into their destinations
+func (l *TemplateLoader) TemplateUsed(ctx context.Context, template *Template, usedTemplates []UsedTemplate) ([]TemplatedFile, error) {
+	templatedFiles := make([]TemplatedFile, 0)
+	for _, used := range usedTemplates {
+		l.logger.Debug("templating used template", "template", template.FullName(), "uses", used.Template.FullName(), "path", used.Destination)
+
+		files, err := l.Load(ctx, used.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load files of used template: %s, %w", used.Template.FullName(), err)
+		}
+
+		dependencyFiles, err := l.TemplateFiles(used.Template, files, used.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("used template: %s, %w", used.Template.FullName(), err)
+		}
+		templatedFiles = append(templatedFiles, dependencyFiles...)
+	}
+
+	return templatedFiles, nil
+}
+
+// renderString runs a go template string, like a path or a mapped input, with the template as data
+func renderString(name string, text string, template *Template) (string, error) {
+	tmpl, err := gotmpl.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	output := bytes.NewBufferString("")
+	if err := tmpl.Execute(output, template); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(output.String()), nil
+}
@@ -0,0 +1,112 @@
+package templates
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateIndexerResolve(t *testing.T) {
+	indexer := NewTemplateIndexer()
+	templates := indexer.Resolve([]Template{
+		{Registry: "team", File: TemplateFile{Name: "service", Uses: []TemplateUse{{Template: "dockerfile"}, {Template: "go/http"}}}},
+		{Registry: "team", File: TemplateFile{Name: "dockerfile", Uses: []TemplateUse{{Template: "default/license"}}}},
+		{Registry: "team", Category: "go", File: TemplateFile{Name: "http"}},
+		{Registry: "default", File: TemplateFile{Name: "license"}},
+		{Registry: "default", File: TemplateFile{Name: "http"}},
+		{Registry: "team", Path: "/registry/ambiguous", File: TemplateFile{Name: "ambiguous", Uses: []TemplateUse{{Template: "http"}}}},
+		{Registry: "team", Path: "/registry/missing", File: TemplateFile{Name: "missing", Uses: []TemplateUse{{Template: "unknown"}}}},
+		{Registry: "team", Path: "/registry/a", File: TemplateFile{Name: "a", Uses: []TemplateUse{{Template: "b"}}}},
+		{Registry: "team", Path: "/registry/b", File: TemplateFile{Name: "b", Uses: []TemplateUse{{Template: "a"}}}},
+	})
+
+	require.Len(t, templates, 5)
+	service := templates[0]
+	require.Len(t, service.Dependencies, 2)
+	assert.Equal(t, "team/dockerfile", service.Dependencies[0].FullName())
+	assert.Equal(t, "team/go/http", service.Dependencies[1].FullName(), "the name is looked up in the registry of the template first")
+	require.Len(t, service.Dependencies[0].Dependencies, 1)
+	assert.Equal(t, "default/license", service.Dependencies[0].Dependencies[0].FullName())
+
+	diagnostics := indexer.Diagnostics()
+	require.Len(t, diagnostics, 4)
+	assert.ErrorContains(t, diagnostics[0], "template uses itself: team/a -> team/b -> team/a")
+	assert.ErrorContains(t, diagnostics[1], "uses: template: http is ambiguous")
+	assert.ErrorContains(t, diagnostics[2], "template uses itself: team/b -> team/a -> team/b")
+	assert.ErrorContains(t, diagnostics[3], "uses: template: unknown not found")
+}
+
+func TestTemplateLoaderTemplateDependencies(t *testing.T) {
+	registryPath := writeTestRegistry(t, map[string]string{
+		"service/scaffold.yaml": `name: service
+input:
+  name:
+    type: string
+uses:
+  - template: dockerfile
+    input:
+      binary: "{{ .Input.name }}-server"
+  - template: readme
+    path: docs
+`,
+		"service/files/main.go":       "package main\n",
+		"service/files/README.md":     "# {{ .Input.name }}\n",
+		"dockerfile/scaffold.yaml":    "name: dockerfile\ndefault:\n  path: deploy\ninput:\n  binary:\n    type: string\n  base:\n    type: string\n    default: alpine\n",
+		"dockerfile/files/Dockerfile": "FROM {{ .Input.base }}\nCMD [\"{{ .Input.binary }}\"]\n",
+		"readme/scaffold.yaml":        "name: readme\nfiles:\n  README.md:\n    mode: append\nuses:\n  - template: license\n",
+		"readme/files/README.md":      "## Usage\n",
+		"license/scaffold.yaml":       "name: license\n",
+		"license/files/LICENSE":       "MIT\n",
+	})
+
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	templates, err := NewTemplateIndexer().Index(ctx, registryPath, ui)
+	require.NoError(t, err)
+
+	var service *Template
+	for i := range templates {
+		if templates[i].File.Name == "service" {
+			service = &templates[i]
+		}
+	}
+	require.NotNil(t, service)
+	service.Input["name"] = "orders"
+
+	dest := t.TempDir()
+	loader := NewTemplateLoader(ui)
+
+	templatedFiles, err := loader.TemplateDependencies(ctx, service, dest)
+	require.NoError(t, err)
+
+	destinations := make(map[string]string)
+	for _, file := range templatedFiles {
+		destinations[file.DestinationPath] = file.Template
+	}
+	assert.Equal(t, map[string]string{
+		path.Join(dest, "deploy", "Dockerfile"): "dockerfile",
+		path.Join(dest, "docs", "README.md"):    "readme",
+		path.Join(dest, "docs", "LICENSE"):      "license",
+	}, destinations)
+
+	files, err := loader.Load(ctx, service)
+	require.NoError(t, err)
+	serviceFiles, err := loader.TemplateFiles(service, files, path.Join(dest, "docs"))
+	require.NoError(t, err)
+
+	require.NoError(t, NewFileWriter().Write(ctx, ui, append(templatedFiles, serviceFiles...)))
+
+	dockerfile, err := os.ReadFile(path.Join(dest, "deploy", "Dockerfile"))
+	require.NoError(t, err)
+	assert.Equal(t, "FROM alpine\nCMD [\"orders-server\"]\n", string(dockerfile))
+
+	readme, err := os.ReadFile(path.Join(dest, "docs", "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# orders\n## Usage\n", string(readme), "the whole file is written before it is appended to")
+}
@@ -1,12 +1,14 @@
 package templates
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path"
+	"slices"
 	"sync"
 
 	"golang.org/x/sync/errgroup"
@@ -37,80 +39,334 @@ func (f *FileWriter) WithPromptOverride(po PromptOverride) *FileWriter {
 func (f *FileWriter) Write(ctx context.Context, ui *slog.Logger, templatedFiles []TemplatedFile) error {
 	var fileExistsLock sync.Mutex
 
-	egrp, _ := errgroup.WithContext(ctx)
+	// Several templated files may target the same destination, i.e. multiple injections into a main.go, those are applied in the order they're given, which is the order of their path in files/
+	destinations := make([]string, 0)
+	filesByDestination := make(map[string][]TemplatedFile)
 	for _, file := range templatedFiles {
+		if _, ok := filesByDestination[file.DestinationPath]; !ok {
+			destinations = append(destinations, file.DestinationPath)
+		}
+
+		filesByDestination[file.DestinationPath] = append(filesByDestination[file.DestinationPath], file)
+	}
+
+	// Conflicts are found before anything is written, so a failed scaffold doesn't leave half of the files behind
+	for _, destination := range destinations {
+		files, err := planDestination(destination, filesByDestination[destination])
+		if err != nil {
+			return err
+		}
+
+		if err := checkInjections(destination, files); err != nil {
+			return err
+		}
+
+		filesByDestination[destination] = files
+	}
+
+	egrp, _ := errgroup.WithContext(ctx)
+	for _, destination := range destinations {
 		egrp.Go(func() error {
-			switch file.Mode {
-			case TemplatedFileWriteModeFile:
-				if _, err := os.Stat(file.DestinationPath); err != nil {
-					if !errors.Is(err, os.ErrNotExist) {
-						return fmt.Errorf("failed to check if file exists: %s, %w", file.DestinationPath, err)
-					}
-				} else {
-					if f.promptOverride != nil {
-						fileExistsLock.Lock()
-						defer fileExistsLock.Unlock()
-
-						override, err := f.promptOverride(file)
-						if err != nil {
-							return fmt.Errorf("failed to get answer to whether a file should be overwritten or not: %w", err)
-						}
-
-						if !override {
-							ui.Warn("Skipping file", "file", file.DestinationPath)
-							return nil
-						}
-					}
+			for _, file := range filesByDestination[destination] {
+				if err := f.writeFile(ui, &fileExistsLock, file); err != nil {
+					return err
 				}
+			}
 
-				if parent := path.Dir(file.DestinationPath); parent != "" && parent != "/" {
-					if err := os.MkdirAll(parent, readWriteExec); err != nil {
-						return fmt.Errorf("failed to create parent dir for: %s, %w", file.DestinationPath, err)
-					}
-				}
+			return nil
+		})
+	}
 
-				ui.Info("writing file", "path", file.DestinationPath)
-				if err := os.WriteFile(file.DestinationPath, file.Content, readExec); err != nil {
-					return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
-				}
-			case TemplatedFileWriteModeAppend:
-				_, err := os.Stat(file.DestinationPath)
+	if err := egrp.Wait(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// planDestination orders the files written to the same destination, when a template uses other templates they may all add to the same file. The file is written whole first, so the other modes apply on top of it.
+// Only one of them can write the whole file, with write or skip-if-exists, as the result would otherwise depend on their order. Files with the same content are written once, with write taking precedence over skip-if-exists
+func planDestination(destination string, files []TemplatedFile) ([]TemplatedFile, error) {
+	var (
+		written *TemplatedFile
+		planned = make([]TemplatedFile, 0, len(files))
+	)
+
+	for _, file := range files {
+		if file.Mode != TemplatedFileWriteModeFile && file.Mode != TemplatedFileWriteModeSkipIfExists {
+			planned = append(planned, file)
+			continue
+		}
+
+		if written == nil {
+			written = &file
+			continue
+		}
+
+		if !bytes.Equal(written.Content, file.Content) {
+			return nil, fmt.Errorf("conflict: %s is written by both %s and %s, with different content", destination, written.Template, file.Template)
+		}
+
+		if file.Mode == TemplatedFileWriteModeFile {
+			written = &file
+		}
+	}
+
+	if written != nil {
+		planned = slices.Insert(planned, 0, *written)
+	}
+
+	reverseInjectionsAfter(planned)
+
+	return planned, nil
+}
+
+// checkInjections applies the planned files to the destination in memory, so a missing anchor is found before anything is written. The merge and go modes can't be known without writing, so the injections after them are left to the write
+func checkInjections(destination string, files []TemplatedFile) error {
+	if !slices.ContainsFunc(files, func(file TemplatedFile) bool { return file.Mode == TemplatedFileWriteModeInject }) {
+		return nil
+	}
+
+	content, err := os.ReadFile(destination)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read file to inject into: %s, %w", destination, err)
+	}
+	exists := err == nil
+
+	for _, file := range files {
+		switch file.Mode {
+		case TemplatedFileWriteModeFile:
+			content, exists = file.Content, true
+		case TemplatedFileWriteModeSkipIfExists:
+			if !exists {
+				content, exists = file.Content, true
+			}
+		case TemplatedFileWriteModeAppend:
+			if !alreadyApplied(content, file.Content) {
+				content, exists = slices.Concat(content, file.Content), true
+			}
+		case TemplatedFileWriteModePrepend:
+			if !alreadyApplied(content, file.Content) {
+				content, exists = slices.Concat(file.Content, content), true
+			}
+		case TemplatedFileWriteModeInject:
+			if !exists {
+				return fmt.Errorf("failed to inject into file: %s, it doesn't exist", destination)
+			}
+
+			if alreadyApplied(content, file.Content) {
+				continue
+			}
+
+			injected, err := inject(content, file.Anchor, file.Position, file.Content)
+			if err != nil {
+				return fmt.Errorf("failed to inject into file: %s, %w", destination, err)
+			}
+			content = injected
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// reverseInjectionsAfter reverses the injections after the same anchor, as each of them goes right after the anchor. The blocks then end up in the order of the template, while keeping the places of the other files
+func reverseInjectionsAfter(files []TemplatedFile) {
+	indexesByAnchor := make(map[string][]int)
+	for i, file := range files {
+		if file.Mode == TemplatedFileWriteModeInject && (file.Position == InjectPositionAfter || file.Position == "") {
+			indexesByAnchor[file.Anchor] = append(indexesByAnchor[file.Anchor], i)
+		}
+	}
+
+	for _, indexes := range indexesByAnchor {
+		for i, j := 0, len(indexes)-1; i < j; i, j = i+1, j-1 {
+			files[indexes[i]], files[indexes[j]] = files[indexes[j]], files[indexes[i]]
+		}
+	}
+}
+
+func (f *FileWriter) writeFile(ui *slog.Logger, fileExistsLock *sync.Mutex, file TemplatedFile) error {
+	switch file.Mode {
+	case TemplatedFileWriteModeFile:
+		if _, err := os.Stat(file.DestinationPath); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to check if file exists: %s, %w", file.DestinationPath, err)
+			}
+		} else {
+			if f.promptOverride != nil {
+				fileExistsLock.Lock()
+				defer fileExistsLock.Unlock()
+
+				override, err := f.promptOverride(file)
 				if err != nil {
-					if !errors.Is(err, os.ErrNotExist) {
-						return fmt.Errorf("failed to check if file exists: %s, %w", file.DestinationPath, err)
-					}
+					return fmt.Errorf("failed to get answer to whether a file should be overwritten or not: %w", err)
 				}
 
-				if errors.Is(err, os.ErrNotExist) {
-					if parent := path.Dir(file.DestinationPath); parent != "" && parent != "/" {
-						if err := os.MkdirAll(parent, readWriteExec); err != nil {
-							return fmt.Errorf("failed to create parent dir for: %s, %w", file.DestinationPath, err)
-						}
-					}
-
-					ui.Info("writing file", "path", file.DestinationPath)
-					if err := os.WriteFile(file.DestinationPath, file.Content, readExec); err != nil {
-						return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
-					}
-				} else {
-					ui.Info("appending file", "path", file.DestinationPath)
-					fileDest, err := os.OpenFile(file.DestinationPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, readExec)
-					if err != nil {
-						return fmt.Errorf("open file for append %s: %w", file.DestinationPath, err)
-					}
-
-					if _, err := fileDest.Write(file.Content); err != nil {
-						return fmt.Errorf("append to file %s: %w", file.DestinationPath, err)
-					}
+				if !override {
+					ui.Warn("Skipping file", "file", file.DestinationPath)
+					return nil
 				}
 			}
+		}
+
+		if err := createParentDir(file.DestinationPath); err != nil {
+			return err
+		}
 
+		ui.Info("writing file", "path", file.DestinationPath)
+		if err := os.WriteFile(file.DestinationPath, file.Content, readExec); err != nil {
+			return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
+		}
+	case TemplatedFileWriteModeAppend:
+		existing, err := os.ReadFile(file.DestinationPath)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to check if file exists: %s, %w", file.DestinationPath, err)
+			}
+		}
+
+		if errors.Is(err, os.ErrNotExist) {
+			if err := createParentDir(file.DestinationPath); err != nil {
+				return err
+			}
+
+			ui.Info("writing file", "path", file.DestinationPath)
+			if err := os.WriteFile(file.DestinationPath, file.Content, readExec); err != nil {
+				return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
+			}
+		} else if alreadyApplied(existing, file.Content) {
+			ui.Info("already applied, skipping", "path", file.DestinationPath)
+		} else {
+			ui.Info("appending file", "path", file.DestinationPath)
+			fileDest, err := os.OpenFile(file.DestinationPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, readExec)
+			if err != nil {
+				return fmt.Errorf("open file for append %s: %w", file.DestinationPath, err)
+			}
+			defer fileDest.Close()
+
+			if _, err := fileDest.Write(file.Content); err != nil {
+				return fmt.Errorf("append to file %s: %w", file.DestinationPath, err)
+			}
+		}
+	case TemplatedFileWriteModePrepend:
+		existing, err := os.ReadFile(file.DestinationPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to check if file exists: %s, %w", file.DestinationPath, err)
+		}
+
+		if alreadyApplied(existing, file.Content) {
+			ui.Info("already applied, skipping", "path", file.DestinationPath)
 			return nil
-		})
+		}
+
+		if err := createParentDir(file.DestinationPath); err != nil {
+			return err
+		}
+
+		ui.Info("prepending file", "path", file.DestinationPath)
+		if err := os.WriteFile(file.DestinationPath, slices.Concat(file.Content, existing), readExec); err != nil {
+			return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
+		}
+	case TemplatedFileWriteModeSkipIfExists:
+		if _, err := os.Stat(file.DestinationPath); err == nil {
+			ui.Info("file exists, skipping", "path", file.DestinationPath)
+			return nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to check if file exists: %s, %w", file.DestinationPath, err)
+		}
+
+		if err := createParentDir(file.DestinationPath); err != nil {
+			return err
+		}
+
+		ui.Info("writing file", "path", file.DestinationPath)
+		if err := os.WriteFile(file.DestinationPath, file.Content, readExec); err != nil {
+			return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
+		}
+	case TemplatedFileWriteModeInject:
+		existing, err := os.ReadFile(file.DestinationPath)
+		if err != nil {
+			return fmt.Errorf("failed to read file to inject into: %s, %w", file.DestinationPath, err)
+		}
+
+		if alreadyApplied(existing, file.Content) {
+			ui.Info("already applied, skipping", "path", file.DestinationPath, "anchor", file.Anchor)
+			return nil
+		}
+
+		injected, err := inject(existing, file.Anchor, file.Position, file.Content)
+		if err != nil {
+			return fmt.Errorf("failed to inject into file: %s, %w", file.DestinationPath, err)
+		}
+
+		ui.Info("injecting into file", "path", file.DestinationPath, "anchor", file.Anchor, "position", file.Position)
+		if err := os.WriteFile(file.DestinationPath, injected, readExec); err != nil {
+			return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
+		}
+	case TemplatedFileWriteModeGoImport, TemplatedFileWriteModeGoFunc, TemplatedFileWriteModeGoStruct, TemplatedFileWriteModeGoInterface:
+		existing, err := os.ReadFile(file.DestinationPath)
+		if err != nil {
+			return fmt.Errorf("failed to read go file to insert into: %s, %w", file.DestinationPath, err)
+		}
+
+		// The go file is parsed to find whether the content is present, which insertGo reports by returning it unchanged
+		inserted, err := insertGo(file.Mode, existing, file.Target, file.Content)
+		if err != nil {
+			return fmt.Errorf("failed to insert into go file: %s, %w", file.DestinationPath, err)
+		}
+
+		if bytes.Equal(existing, inserted) {
+			ui.Info("already applied, skipping", "path", file.DestinationPath, "target", file.Target)
+			return nil
+		}
+
+		ui.Info("inserting into go file", "path", file.DestinationPath, "mode", file.Mode, "target", file.Target)
+		if err := os.WriteFile(file.DestinationPath, inserted, readExec); err != nil {
+			return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
+		}
+	case TemplatedFileWriteModeMergeYAML, TemplatedFileWriteModeMergeJSON:
+		existing, err := os.ReadFile(file.DestinationPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read file to merge into: %s, %w", file.DestinationPath, err)
+		}
+
+		merge := mergeYAML
+		if file.Mode == TemplatedFileWriteModeMergeJSON {
+			merge = mergeJSON
+		}
+
+		merged, err := merge(existing, file.Content)
+		if err != nil {
+			return fmt.Errorf("failed to merge into file: %s, %w", file.DestinationPath, err)
+		}
+
+		if bytes.Equal(existing, merged) {
+			ui.Info("already applied, skipping", "path", file.DestinationPath)
+			return nil
+		}
+
+		if err := createParentDir(file.DestinationPath); err != nil {
+			return err
+		}
+
+		ui.Info("merging into file", "path", file.DestinationPath)
+		if err := os.WriteFile(file.DestinationPath, merged, readExec); err != nil {
+			return fmt.Errorf("failed to write file: %s, %w", file.DestinationPath, err)
+		}
+	default:
+		return fmt.Errorf("unknown write mode: %s for file: %s", file.Mode, file.DestinationPath)
 	}
 
-	if err := egrp.Wait(); err != nil {
-		return err
+	return nil
+}
+
+func createParentDir(filePath string) error {
+	if parent := path.Dir(filePath); parent != "" && parent != "/" {
+		if err := os.MkdirAll(parent, readWriteExec); err != nil {
+			return fmt.Errorf("failed to create parent dir for: %s, %w", filePath, err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,227 @@
+package templates
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWriterModesFromConfig(t *testing.T) {
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	dest := t.TempDir()
+
+	require.NoError(t, os.WriteFile(path.Join(dest, "main.go"), []byte("package main\n\nfunc main() {\n\t// scaffold:routes\n}\n"), readExec))
+	require.NoError(t, os.WriteFile(path.Join(dest, "CHANGELOG.md"), []byte("## v1\n"), readExec))
+	require.NoError(t, os.WriteFile(path.Join(dest, "existing.txt"), []byte("keep me\n"), readExec))
+
+	template := &Template{
+		File: TemplateFile{
+			Name: "modes",
+			Files: map[string]TemplateFileConfig{
+				"main.go":      {Mode: "inject", Anchor: "// scaffold:routes"},
+				"CHANGELOG.md": {Mode: "prepend"},
+				"existing.txt": {Mode: "skip-if-exists"},
+			},
+		},
+		Input: map[string]string{},
+	}
+	files := []File{
+		{content: []byte("\troutes.Add()\n"), RelPath: "main.go.gotmpl"},
+		{content: []byte("## v2\n"), RelPath: "CHANGELOG.md"},
+		{content: []byte("replaced\n"), RelPath: "existing.txt"},
+	}
+
+	loader := NewTemplateLoader(ui)
+	writer := NewFileWriter()
+
+	// Running twice shows that the modes are idempotent
+	for range 2 {
+		templatedFiles, err := loader.TemplateFiles(template, files, dest)
+		require.NoError(t, err)
+		require.NoError(t, writer.Write(ctx, ui, templatedFiles))
+	}
+
+	mainFile, err := os.ReadFile(path.Join(dest, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {\n\t// scaffold:routes\n\troutes.Add()\n}\n", string(mainFile))
+
+	changelog, err := os.ReadFile(path.Join(dest, "CHANGELOG.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "## v2\n## v1\n", string(changelog))
+
+	existing, err := os.ReadFile(path.Join(dest, "existing.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "keep me\n", string(existing))
+}
+
+func TestParseWriteMode(t *testing.T) {
+	mode, err := ParseWriteMode("")
+	require.NoError(t, err)
+	assert.Equal(t, TemplatedFileWriteMode(TemplatedFileWriteModeFile), mode)
+
+	mode, err = ParseWriteMode("merge-yaml")
+	require.NoError(t, err)
+	assert.Equal(t, TemplatedFileWriteMode(TemplatedFileWriteModeMergeYAML), mode)
+	assert.Equal(t, "merge-yaml", mode.String())
+
+	_, err = ParseWriteMode("overwrite")
+	assert.Error(t, err)
+}
+
+func TestTemplatedFileDescribe(t *testing.T) {
+	assert.Equal(t, "write", TemplatedFile{Mode: TemplatedFileWriteModeFile}.Describe())
+	assert.Equal(t, "inject after // scaffold:routes", TemplatedFile{Mode: TemplatedFileWriteModeInject, Anchor: "// scaffold:routes"}.Describe())
+	assert.Equal(t, "go-func main", TemplatedFile{Mode: TemplatedFileWriteModeGoFunc, Target: "main"}.Describe())
+}
+
+func TestFileWriterConflicts(t *testing.T) {
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	dest := t.TempDir()
+
+	err := NewFileWriter().Write(ctx, ui, []TemplatedFile{
+		{DestinationPath: path.Join(dest, "other.txt"), Mode: TemplatedFileWriteModeFile, Content: []byte("other\n"), Template: "service"},
+		{DestinationPath: path.Join(dest, "main.go"), Mode: TemplatedFileWriteModeFile, Content: []byte("package main\n"), Template: "service"},
+		{DestinationPath: path.Join(dest, "main.go"), Mode: TemplatedFileWriteModeFile, Content: []byte("package app\n"), Template: "library"},
+	})
+	assert.ErrorContains(t, err, "main.go is written by both service and library")
+
+	_, err = os.Stat(path.Join(dest, "other.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "nothing is written if there is a conflict")
+
+	require.NoError(t, NewFileWriter().Write(ctx, ui, []TemplatedFile{
+		{DestinationPath: path.Join(dest, "LICENSE"), Mode: TemplatedFileWriteModeFile, Content: []byte("MIT\n"), Template: "service"},
+		{DestinationPath: path.Join(dest, "LICENSE"), Mode: TemplatedFileWriteModeFile, Content: []byte("MIT\n"), Template: "library"},
+	}), "the same content isn't a conflict")
+
+	for _, mode := range []TemplatedFileWriteMode{TemplatedFileWriteModeSkipIfExists, TemplatedFileWriteModeFile} {
+		err := NewFileWriter().Write(ctx, ui, []TemplatedFile{
+			{DestinationPath: path.Join(dest, "Makefile"), Mode: TemplatedFileWriteModeSkipIfExists, Content: []byte("build:\n"), Template: "service"},
+			{DestinationPath: path.Join(dest, "Makefile"), Mode: mode, Content: []byte("test:\n"), Template: "library"},
+		})
+		assert.ErrorContains(t, err, "Makefile is written by both service and library", "skip-if-exists conflicts with %s", mode)
+	}
+
+	require.NoError(t, os.WriteFile(path.Join(dest, "README.md"), []byte("# existing\n"), readExec))
+	require.NoError(t, NewFileWriter().Write(ctx, ui, []TemplatedFile{
+		{DestinationPath: path.Join(dest, "README.md"), Mode: TemplatedFileWriteModeSkipIfExists, Content: []byte("# readme\n"), Template: "service"},
+		{DestinationPath: path.Join(dest, "README.md"), Mode: TemplatedFileWriteModeFile, Content: []byte("# readme\n"), Template: "library"},
+	}))
+
+	readme, err := os.ReadFile(path.Join(dest, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# readme\n", string(readme), "write takes precedence over skip-if-exists with the same content")
+}
+
+func TestFileWriterChecksInjections(t *testing.T) {
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	dest := t.TempDir()
+
+	require.NoError(t, os.WriteFile(path.Join(dest, "main.go"), []byte("package main\n\n// scaffold:routes\n"), readExec))
+
+	err := NewFileWriter().Write(ctx, ui, []TemplatedFile{
+		{DestinationPath: path.Join(dest, "other.txt"), Mode: TemplatedFileWriteModeFile, Content: []byte("other\n")},
+		{DestinationPath: path.Join(dest, "main.go"), Mode: TemplatedFileWriteModeInject, Anchor: "// scaffold:routes", Content: []byte("routes()\n")},
+		{DestinationPath: path.Join(dest, "main.go"), Mode: TemplatedFileWriteModeInject, Anchor: "// scaffold:handlers", Content: []byte("handlers()\n")},
+	})
+	assert.ErrorContains(t, err, "anchor: '// scaffold:handlers' was not found")
+
+	err = NewFileWriter().Write(ctx, ui, []TemplatedFile{
+		{DestinationPath: path.Join(dest, "main.go"), Mode: TemplatedFileWriteModeInject, Anchor: "// scaffold:routes", Position: "above", Content: []byte("routes()\n")},
+	})
+	assert.ErrorContains(t, err, "invalid inject position: 'above'")
+
+	err = NewFileWriter().Write(ctx, ui, []TemplatedFile{
+		{DestinationPath: path.Join(dest, "missing.go"), Mode: TemplatedFileWriteModeInject, Anchor: "// scaffold:routes", Content: []byte("routes()\n")},
+	})
+	assert.ErrorContains(t, err, "missing.go, it doesn't exist")
+
+	assert.NoFileExists(t, path.Join(dest, "other.txt"), "nothing is written if an injection can't be applied")
+	mainFile, err := os.ReadFile(path.Join(dest, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\n// scaffold:routes\n", string(mainFile))
+
+	require.NoError(t, NewFileWriter().Write(ctx, ui, []TemplatedFile{
+		{DestinationPath: path.Join(dest, "app.go"), Mode: TemplatedFileWriteModeFile, Content: []byte("package app\n")},
+		{DestinationPath: path.Join(dest, "app.go"), Mode: TemplatedFileWriteModeAppend, Content: []byte("// scaffold:routes\n")},
+		{DestinationPath: path.Join(dest, "app.go"), Mode: TemplatedFileWriteModeInject, Anchor: "// scaffold:routes", Content: []byte("routes()\n")},
+	}), "the anchor can be added by the files before the injection")
+
+	app, err := os.ReadFile(path.Join(dest, "app.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package app\n// scaffold:routes\nroutes()\n", string(app))
+}
+
+func TestFileWriterInjectionOrder(t *testing.T) {
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	files := map[string]string{
+		"routes/scaffold.yaml": `name: routes
+files:
+  a_users.go:
+    rename: main.go
+    mode: inject
+    anchor: "// scaffold:routes"
+  b_orders.go:
+    rename: main.go
+    mode: inject
+    anchor: "// scaffold:routes"
+  c_health.go:
+    rename: main.go
+    mode: inject
+    anchor: "// scaffold:routes"
+`,
+		"routes/files/a_users.go":  "\troutes.Add(\"users\")\n",
+		"routes/files/b_orders.go": "\troutes.Add(\"orders\")\n",
+		"routes/files/c_health.go": "\troutes.Add(\"health\")\n",
+	}
+	registryPath := writeTestRegistry(t, files)
+
+	templates, err := NewTemplateIndexer().Index(ctx, registryPath, ui)
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+
+	loader := NewTemplateLoader(ui)
+
+	// The files are read concurrently, so the order is checked over a number of runs
+	for range 20 {
+		dest := t.TempDir()
+		require.NoError(t, os.WriteFile(path.Join(dest, "main.go"), []byte("package main\n\nfunc main() {\n\t// scaffold:routes\n}\n"), readExec))
+
+		loaded, err := loader.Load(ctx, &templates[0])
+		require.NoError(t, err)
+
+		templatedFiles, err := loader.TemplateFiles(&templates[0], loaded, dest)
+		require.NoError(t, err)
+		require.NoError(t, NewFileWriter().Write(ctx, ui, templatedFiles))
+
+		mainFile, err := os.ReadFile(path.Join(dest, "main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, "package main\n\nfunc main() {\n\t// scaffold:routes\n\troutes.Add(\"users\")\n\troutes.Add(\"orders\")\n\troutes.Add(\"health\")\n}\n", string(mainFile), "the injections are in the order of the files")
+	}
+}
+
+func TestFileWriterGoImportAppliedByAST(t *testing.T) {
+	ctx := t.Context()
+	ui := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	dest := t.TempDir()
+
+	// The import path is in the file, but not as an import
+	require.NoError(t, os.WriteFile(path.Join(dest, "main.go"), []byte("package main\n\nconst imports = `\n\"net/http\"\n`\n"), readExec))
+
+	require.NoError(t, NewFileWriter().Write(ctx, ui, []TemplatedFile{
+		{DestinationPath: path.Join(dest, "main.go"), Mode: TemplatedFileWriteModeGoImport, Content: []byte("\"net/http\"\n")},
+	}))
+
+	mainFile, err := os.ReadFile(path.Join(dest, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"net/http\"\n)\n\nconst imports = `\n\"net/http\"\n`\n", string(mainFile))
+}
@@ -7,8 +7,11 @@ import (
 	"github.com/kjuulh/scaffold/cmd"
 )
 
+// version is set by the release build through ldflags
+var version = "dev"
+
 func main() {
-	if err := cmd.Execute(); err != nil {
+	if err := cmd.Execute(version); err != nil {
 		fmt.Printf("scaffold failed: %s\n", err.Error())
 		os.Exit(1)
 	}
@@ -103,6 +103,10 @@ func (f *TestFixture) ScaffoldTest(testName string, input func(fixture *Scaffold
 			templatedFiles, err := loader.TemplateFiles(template, files, path.Join(actualPath, templatePath))
 			require.NoError(t, err, "failed to template files")
 
+			dependencyFiles, err := loader.TemplateDependencies(ctx, template, path.Join(actualPath, templatePath))
+			require.NoError(t, err, "failed to template the used templates")
+			templatedFiles = append(templatedFiles, dependencyFiles...)
+
 			err = os.RemoveAll(actualPath)
 			require.NoError(t, err)
 